@@ -0,0 +1,366 @@
+package device
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// 密钥状态
+const (
+	KeyStatusOK         = "ok"
+	KeyStatusGenerating = "generating"
+	KeyStatusCorrupt    = "corrupt"
+)
+
+// sha256WithRSAEncryption
+const signatureAlgorithmSHA256WithRSA = "1.2.840.113549.1.1.11"
+
+// 证书主题，只包含常用字段
+type DistinguishedName struct {
+	Country            string `xml:"tas:Country,omitempty"`
+	Organization       string `xml:"tas:Organization,omitempty"`
+	OrganizationalUnit string `xml:"tas:OrganizationalUnit,omitempty"`
+	StateOrProvince    string `xml:"tas:StateOrProvinceName,omitempty"`
+	Locality           string `xml:"tas:Locality,omitempty"`
+	CommonName         string `xml:"tas:CommonName,omitempty"`
+}
+
+// 密钥库中的证书，Data为DER编码
+type KeystoreCertificate struct {
+	CertificateID string
+	KeyID         string
+	Alias         string
+	Data          []byte
+}
+
+type keystoreCertificate struct {
+	CertificateID      string `xml:"CertificateID"`
+	KeyID              string `xml:"KeyID"`
+	Alias              string `xml:"Alias"`
+	CertificateContent string `xml:"CertificateContent"`
+}
+
+type CreateRSAKeyPairRequest struct {
+	XMLName   string `xml:"tas:CreateRSAKeyPair"`
+	KeyLength int    `xml:"tas:KeyLength"`
+	Alias     string `xml:"tas:Alias,omitempty"`
+}
+
+type CreateRSAKeyPairResponse struct {
+	XMLName               string `xml:"Envelope"`
+	KeyID                 string `xml:"Body>CreateRSAKeyPairResponse>KeyID"`
+	EstimatedCreationTime string `xml:"Body>CreateRSAKeyPairResponse>EstimatedCreationTime"`
+}
+
+type KeyStatusRequest struct {
+	XMLName string `xml:"tas:GetKeyStatus"`
+	KeyID   string `xml:"tas:KeyID"`
+}
+
+type KeyStatusResponse struct {
+	XMLName   string `xml:"Envelope"`
+	KeyStatus string `xml:"Body>GetKeyStatusResponse>KeyStatus"`
+}
+
+type CreatePKCS10CSRRequest struct {
+	XMLName            string            `xml:"tas:CreatePKCS10CSR"`
+	Subject            DistinguishedName `xml:"tas:Subject"`
+	KeyID              string            `xml:"tas:KeyID"`
+	SignatureAlgorithm string            `xml:"tas:SignatureAlgorithm>tas:algorithm"`
+}
+
+type CreatePKCS10CSRResponse struct {
+	XMLName   string `xml:"Envelope"`
+	PKCS10CSR string `xml:"Body>CreatePKCS10CSRResponse>PKCS10CSR"`
+}
+
+type UploadCertificateRequest struct {
+	XMLName            string `xml:"tas:UploadCertificate"`
+	Certificate        string `xml:"tas:Certificate"`
+	Alias              string `xml:"tas:Alias,omitempty"`
+	PrivateKeyRequired bool   `xml:"tas:PrivateKeyRequired"`
+}
+
+type UploadCertificateResponse struct {
+	XMLName       string `xml:"Envelope"`
+	CertificateID string `xml:"Body>UploadCertificateResponse>CertificateID"`
+	KeyID         string `xml:"Body>UploadCertificateResponse>KeyID"`
+}
+
+type AllCertificatesRequest struct {
+	XMLName string `xml:"tas:GetAllCertificates"`
+}
+
+type AllCertificatesResponse struct {
+	XMLName     string                `xml:"Envelope"`
+	Certificate []keystoreCertificate `xml:"Body>GetAllCertificatesResponse>Certificate"`
+}
+
+type DeleteCertificateRequest struct {
+	XMLName       string `xml:"tas:DeleteCertificate"`
+	CertificateID string `xml:"tas:CertificateID"`
+}
+
+type CreateCertificationPathRequest struct {
+	XMLName        string   `xml:"tas:CreateCertificationPath"`
+	CertificateIDs []string `xml:"tas:CertificateIDs>tas:CertificateID"`
+	Alias          string   `xml:"tas:Alias,omitempty"`
+}
+
+type CreateCertificationPathResponse struct {
+	XMLName             string `xml:"Envelope"`
+	CertificationPathID string `xml:"Body>CreateCertificationPathResponse>CertificationPathID"`
+}
+
+type AddServerCertificateAssignmentRequest struct {
+	XMLName             string `xml:"tas:AddServerCertificateAssignment"`
+	CertificationPathID string `xml:"tas:CertificationPathID"`
+}
+
+type RemoveServerCertificateAssignmentRequest struct {
+	XMLName             string `xml:"tas:RemoveServerCertificateAssignment"`
+	CertificationPathID string `xml:"tas:CertificationPathID"`
+}
+
+type AssignedServerCertificatesRequest struct {
+	XMLName string `xml:"tas:GetAssignedServerCertificates"`
+}
+
+type AssignedServerCertificatesResponse struct {
+	XMLName             string   `xml:"Envelope"`
+	CertificationPathID []string `xml:"Body>GetAssignedServerCertificatesResponse>CertificationPathID"`
+}
+
+type AdvancedSecurityEmptyResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+// 高级安全服务地址，设备不支持时返回错误
+func (device *OnvifDevice) securityServiceAddr() (string, error) {
+	addr, err := device.serviceAddr(AdvancedSecurityServiceNamespace)
+	if err != nil {
+		return "", err
+	}
+	if addr == "" {
+		return "", errors.New("the device do not support advanced security")
+	}
+	return addr, nil
+}
+
+func (device *OnvifDevice) callSecurityMethod(action string, request, response interface{}) error {
+	addr, err := device.securityServiceAddr()
+	if err != nil {
+		return err
+	}
+
+	err = device.callMethod(addr, "http://www.onvif.org/ver10/advancedsecurity/wsdl/"+action, request, response)
+	if err != nil {
+		log.Println(action, "fail", err)
+		return err
+	}
+	return nil
+}
+
+// 在设备密钥库中生成RSA密钥对，返回密钥ID和预计生成时间
+func (device *OnvifDevice) CreateRSAKeyPair(keyLength int, alias string) (string, time.Duration, error) {
+	request := CreateRSAKeyPairRequest{KeyLength: keyLength, Alias: alias}
+	response := &CreateRSAKeyPairResponse{}
+	if err := device.callSecurityMethod("CreateRSAKeyPair", request, response); err != nil {
+		return "", 0, err
+	}
+
+	estimated, err := parseDuration(response.EstimatedCreationTime)
+	if err != nil {
+		return "", 0, err
+	}
+	return response.KeyID, estimated, nil
+}
+
+// 获取密钥状态：ok、generating或corrupt
+func (device *OnvifDevice) GetKeyStatus(keyID string) (string, error) {
+	request := KeyStatusRequest{KeyID: keyID}
+	response := &KeyStatusResponse{}
+	if err := device.callSecurityMethod("GetKeyStatus", request, response); err != nil {
+		return "", err
+	}
+	return response.KeyStatus, nil
+}
+
+// 用密钥库中的密钥生成PKCS#10证书请求
+func (device *OnvifDevice) CreatePKCS10CSR(keyID string, subject DistinguishedName) (*x509.CertificateRequest, error) {
+	request := CreatePKCS10CSRRequest{
+		Subject:            subject,
+		KeyID:              keyID,
+		SignatureAlgorithm: signatureAlgorithmSHA256WithRSA,
+	}
+	response := &CreatePKCS10CSRResponse{}
+	if err := device.callSecurityMethod("CreatePKCS10CSR", request, response); err != nil {
+		return nil, err
+	}
+
+	data, err := decodeBase64(response.PKCS10CSR)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificateRequest(data)
+}
+
+// 上传DER编码的证书，privateKeyRequired为true时设备密钥库中必须有对应私钥。返回证书ID和密钥ID
+func (device *OnvifDevice) UploadCertificate(certificate []byte, alias string, privateKeyRequired bool) (string, string, error) {
+	request := UploadCertificateRequest{
+		Certificate:        base64.StdEncoding.EncodeToString(certificate),
+		Alias:              alias,
+		PrivateKeyRequired: privateKeyRequired,
+	}
+	response := &UploadCertificateResponse{}
+	if err := device.callSecurityMethod("UploadCertificate", request, response); err != nil {
+		return "", "", err
+	}
+	return response.CertificateID, response.KeyID, nil
+}
+
+// 获取密钥库中的所有证书
+func (device *OnvifDevice) GetAllCertificates() ([]KeystoreCertificate, error) {
+	var request AllCertificatesRequest
+	response := &AllCertificatesResponse{}
+	if err := device.callSecurityMethod("GetAllCertificates", request, response); err != nil {
+		return nil, err
+	}
+
+	var certificates []KeystoreCertificate
+	for _, raw := range response.Certificate {
+		data, err := decodeBase64(raw.CertificateContent)
+		if err != nil {
+			return nil, fmt.Errorf("certificate %s: %v", raw.CertificateID, err)
+		}
+		certificates = append(certificates, KeystoreCertificate{
+			CertificateID: raw.CertificateID,
+			KeyID:         raw.KeyID,
+			Alias:         raw.Alias,
+			Data:          data,
+		})
+	}
+	return certificates, nil
+}
+
+// 删除密钥库中的证书
+func (device *OnvifDevice) DeleteCertificate(certificateID string) error {
+	request := DeleteCertificateRequest{CertificateID: certificateID}
+	return device.callSecurityMethod("DeleteCertificate", request, &AdvancedSecurityEmptyResponse{})
+}
+
+// 创建证书链，certificateIDs从服务器证书开始依次到根证书
+func (device *OnvifDevice) CreateCertificationPath(certificateIDs []string, alias string) (string, error) {
+	if len(certificateIDs) == 0 {
+		return "", errors.New("certification path is empty")
+	}
+
+	request := CreateCertificationPathRequest{CertificateIDs: certificateIDs, Alias: alias}
+	response := &CreateCertificationPathResponse{}
+	if err := device.callSecurityMethod("CreateCertificationPath", request, response); err != nil {
+		return "", err
+	}
+	return response.CertificationPathID, nil
+}
+
+// 将证书链指定为TLS服务器证书
+func (device *OnvifDevice) AddServerCertificateAssignment(certificationPathID string) error {
+	request := AddServerCertificateAssignmentRequest{CertificationPathID: certificationPathID}
+	return device.callSecurityMethod("AddServerCertificateAssignment", request, &AdvancedSecurityEmptyResponse{})
+}
+
+// 取消TLS服务器证书指定
+func (device *OnvifDevice) RemoveServerCertificateAssignment(certificationPathID string) error {
+	request := RemoveServerCertificateAssignmentRequest{CertificationPathID: certificationPathID}
+	return device.callSecurityMethod("RemoveServerCertificateAssignment", request, &AdvancedSecurityEmptyResponse{})
+}
+
+// 获取当前指定为TLS服务器证书的证书链
+func (device *OnvifDevice) GetAssignedServerCertificates() ([]string, error) {
+	var request AssignedServerCertificatesRequest
+	response := &AssignedServerCertificatesResponse{}
+	if err := device.callSecurityMethod("GetAssignedServerCertificates", request, response); err != nil {
+		return nil, err
+	}
+	return response.CertificationPathID, nil
+}
+
+// 通过高级安全服务部署TLS服务器证书：设备生成密钥，sign用内部CA签发证书请求，
+// 上传签名证书和CA证书链(DER编码，从中间证书到根证书)并替换原有的服务器证书指定
+func (device *OnvifDevice) InstallTLSServerCertificate(keyLength int, subject DistinguishedName, caChain [][]byte,
+	sign func(csr *x509.CertificateRequest) ([]byte, error), timeout time.Duration) error {
+	keyID, estimated, err := device.CreateRSAKeyPair(keyLength, "")
+	if err != nil {
+		return err
+	}
+	time.Sleep(estimated)
+
+	deadline := time.Now().Add(timeout)
+	for {
+		status, err := device.GetKeyStatus(keyID)
+		if err != nil {
+			return err
+		}
+		if status == KeyStatusOK {
+			break
+		}
+		if status == KeyStatusCorrupt {
+			return fmt.Errorf("key %s is corrupt", keyID)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("key %s not ready after %v", keyID, timeout)
+		}
+		time.Sleep(pollInterval)
+	}
+
+	csr, err := device.CreatePKCS10CSR(keyID, subject)
+	if err != nil {
+		return err
+	}
+	signed, err := sign(csr)
+	if err != nil {
+		return err
+	}
+
+	certificateID, _, err := device.UploadCertificate(signed, subject.CommonName, true)
+	if err != nil {
+		return err
+	}
+	path := []string{certificateID}
+	for _, ca := range caChain {
+		caID, _, err := device.UploadCertificate(ca, "", false)
+		if err != nil {
+			return err
+		}
+		path = append(path, caID)
+	}
+
+	pathID, err := device.CreateCertificationPath(path, subject.CommonName)
+	if err != nil {
+		return err
+	}
+
+	assigned, err := device.GetAssignedServerCertificates()
+	if err != nil {
+		return err
+	}
+	for _, old := range assigned {
+		if err := device.RemoveServerCertificateAssignment(old); err != nil {
+			return err
+		}
+	}
+
+	if err := device.AddServerCertificateAssignment(pathID); err != nil {
+		// 恢复原有的服务器证书，避免设备没有可用的HTTPS证书
+		for _, old := range assigned {
+			device.AddServerCertificateAssignment(old)
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,329 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 音频编码格式
+const (
+	AudioEncodingG711 = "G711"
+	AudioEncodingG726 = "G726"
+	AudioEncodingAAC  = "AAC"
+)
+
+// 音频输入，例如摄像机的麦克风
+type AudioSource struct {
+	Token    string `xml:"token,attr"`
+	Channels int    `xml:"Channels"`
+}
+
+// 音频输出，例如摄像机的喇叭
+type AudioOutput struct {
+	Token string `xml:"token,attr"`
+}
+
+// 音频输出配置
+type AudioOutputConfiguration struct {
+	Token       string `xml:"token,attr"`
+	Name        string `xml:"Name"`
+	UseCount    int    `xml:"UseCount"`
+	OutputToken string `xml:"OutputToken"`
+	SendPrimacy string `xml:"SendPrimacy"`
+	OutputLevel int    `xml:"OutputLevel"`
+}
+
+// 音频源配置可选参数
+type AudioSourceConfigurationOptions struct {
+	InputTokensAvailable []string `xml:"InputTokensAvailable"`
+}
+
+// 某种音频编码格式可选的码率(kbps)和采样率(kHz)
+type AudioEncoderConfigurationOption struct {
+	Encoding       string `xml:"Encoding"`
+	BitrateList    []int  `xml:"BitrateList>Items"`
+	SampleRateList []int  `xml:"SampleRateList>Items"`
+}
+
+// 音频输出配置可选参数
+type AudioOutputConfigurationOptions struct {
+	OutputTokensAvailable []string `xml:"OutputTokensAvailable"`
+	SendPrimacyOptions    []string `xml:"SendPrimacyOptions"`
+	OutputLevelRange      IntRange `xml:"OutputLevelRange"`
+}
+
+type setAudioEncoderConfiguration struct {
+	Token          string       `xml:"token,attr"`
+	Name           string       `xml:"tt:Name"`
+	UseCount       int          `xml:"tt:UseCount"`
+	Encoding       string       `xml:"tt:Encoding"`
+	Bitrate        int          `xml:"tt:Bitrate"`
+	SampleRate     int          `xml:"tt:SampleRate"`
+	Multicast      setMulticast `xml:"tt:Multicast"`
+	SessionTimeout string       `xml:"tt:SessionTimeout,omitempty"`
+}
+
+type AudioSourcesRequest struct {
+	XMLName string `xml:"trt:GetAudioSources"`
+}
+
+type AudioSourcesResponse struct {
+	XMLName      string        `xml:"Envelope"`
+	AudioSources []AudioSource `xml:"Body>GetAudioSourcesResponse>AudioSources"`
+}
+
+type AudioOutputsRequest struct {
+	XMLName string `xml:"trt:GetAudioOutputs"`
+}
+
+type AudioOutputsResponse struct {
+	XMLName      string        `xml:"Envelope"`
+	AudioOutputs []AudioOutput `xml:"Body>GetAudioOutputsResponse>AudioOutputs"`
+}
+
+type AudioSourceConfigurationsRequest struct {
+	XMLName string `xml:"trt:GetAudioSourceConfigurations"`
+}
+
+type AudioSourceConfigurationsResponse struct {
+	XMLName        string                     `xml:"Envelope"`
+	Configurations []AudioSourceConfiguration `xml:"Body>GetAudioSourceConfigurationsResponse>Configurations"`
+}
+
+type AudioSourceConfigurationOptionsRequest struct {
+	XMLName            string `xml:"trt:GetAudioSourceConfigurationOptions"`
+	ConfigurationToken string `xml:"trt:ConfigurationToken,omitempty"`
+	ProfileToken       string `xml:"trt:ProfileToken,omitempty"`
+}
+
+type AudioSourceConfigurationOptionsResponse struct {
+	XMLName string                          `xml:"Envelope"`
+	Options AudioSourceConfigurationOptions `xml:"Body>GetAudioSourceConfigurationOptionsResponse>Options"`
+}
+
+type AudioEncoderConfigurationsRequest struct {
+	XMLName string `xml:"trt:GetAudioEncoderConfigurations"`
+}
+
+type AudioEncoderConfigurationsResponse struct {
+	XMLName        string                      `xml:"Envelope"`
+	Configurations []AudioEncoderConfiguration `xml:"Body>GetAudioEncoderConfigurationsResponse>Configurations"`
+}
+
+type AudioEncoderConfigurationOptionsRequest struct {
+	XMLName            string `xml:"trt:GetAudioEncoderConfigurationOptions"`
+	ConfigurationToken string `xml:"trt:ConfigurationToken,omitempty"`
+	ProfileToken       string `xml:"trt:ProfileToken,omitempty"`
+}
+
+type AudioEncoderConfigurationOptionsResponse struct {
+	XMLName string                            `xml:"Envelope"`
+	Options []AudioEncoderConfigurationOption `xml:"Body>GetAudioEncoderConfigurationOptionsResponse>Options>Options"`
+}
+
+type SetAudioEncoderConfigurationRequest struct {
+	XMLName          string                       `xml:"trt:SetAudioEncoderConfiguration"`
+	Configuration    setAudioEncoderConfiguration `xml:"trt:Configuration"`
+	ForcePersistence bool                         `xml:"trt:ForcePersistence"`
+}
+
+type AudioOutputConfigurationsRequest struct {
+	XMLName string `xml:"trt:GetAudioOutputConfigurations"`
+}
+
+type AudioOutputConfigurationsResponse struct {
+	XMLName        string                     `xml:"Envelope"`
+	Configurations []AudioOutputConfiguration `xml:"Body>GetAudioOutputConfigurationsResponse>Configurations"`
+}
+
+type AudioOutputConfigurationOptionsRequest struct {
+	XMLName            string `xml:"trt:GetAudioOutputConfigurationOptions"`
+	ConfigurationToken string `xml:"trt:ConfigurationToken,omitempty"`
+	ProfileToken       string `xml:"trt:ProfileToken,omitempty"`
+}
+
+type AudioOutputConfigurationOptionsResponse struct {
+	XMLName string                          `xml:"Envelope"`
+	Options AudioOutputConfigurationOptions `xml:"Body>GetAudioOutputConfigurationOptionsResponse>Options"`
+}
+
+type AudioEmptyResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+// 获取所有音频输入
+func (device *OnvifDevice) GetAudioSources() ([]AudioSource, error) {
+	var request AudioSourcesRequest
+	response := &AudioSourcesResponse{}
+	if err := device.callMediaMethod("GetAudioSources", request, response); err != nil {
+		return nil, err
+	}
+	return response.AudioSources, nil
+}
+
+// 获取所有音频输出
+func (device *OnvifDevice) GetAudioOutputs() ([]AudioOutput, error) {
+	var request AudioOutputsRequest
+	response := &AudioOutputsResponse{}
+	if err := device.callMediaMethod("GetAudioOutputs", request, response); err != nil {
+		return nil, err
+	}
+	return response.AudioOutputs, nil
+}
+
+// 获取所有音频源配置
+func (device *OnvifDevice) GetAudioSourceConfigurations() ([]AudioSourceConfiguration, error) {
+	var request AudioSourceConfigurationsRequest
+	response := &AudioSourceConfigurationsResponse{}
+	if err := device.callMediaMethod("GetAudioSourceConfigurations", request, response); err != nil {
+		return nil, err
+	}
+	return response.Configurations, nil
+}
+
+// 获取音频源配置的可选参数，configurationToken、profileToken为空时不指定
+func (device *OnvifDevice) GetAudioSourceConfigurationOptions(configurationToken, profileToken string) (*AudioSourceConfigurationOptions, error) {
+	request := AudioSourceConfigurationOptionsRequest{
+		ConfigurationToken: configurationToken,
+		ProfileToken:       profileToken,
+	}
+	response := &AudioSourceConfigurationOptionsResponse{}
+	if err := device.callMediaMethod("GetAudioSourceConfigurationOptions", request, response); err != nil {
+		return nil, err
+	}
+	return &response.Options, nil
+}
+
+// 获取所有音频编码配置
+func (device *OnvifDevice) GetAudioEncoderConfigurations() ([]AudioEncoderConfiguration, error) {
+	var request AudioEncoderConfigurationsRequest
+	response := &AudioEncoderConfigurationsResponse{}
+	if err := device.callMediaMethod("GetAudioEncoderConfigurations", request, response); err != nil {
+		return nil, err
+	}
+	return response.Configurations, nil
+}
+
+// 获取音频编码配置的可选参数，configurationToken、profileToken为空时不指定
+func (device *OnvifDevice) GetAudioEncoderConfigurationOptions(configurationToken, profileToken string) ([]AudioEncoderConfigurationOption, error) {
+	request := AudioEncoderConfigurationOptionsRequest{
+		ConfigurationToken: configurationToken,
+		ProfileToken:       profileToken,
+	}
+	response := &AudioEncoderConfigurationOptionsResponse{}
+	if err := device.callMediaMethod("GetAudioEncoderConfigurationOptions", request, response); err != nil {
+		return nil, err
+	}
+	return response.Options, nil
+}
+
+// 检查音频编码配置是否在设备声明的可选参数内
+func validateAudioEncoderConfiguration(configuration AudioEncoderConfiguration, options []AudioEncoderConfigurationOption) error {
+	var encodings []string
+	var option *AudioEncoderConfigurationOption
+	for i := range options {
+		encodings = append(encodings, options[i].Encoding)
+		if options[i].Encoding == configuration.Encoding {
+			option = &options[i]
+		}
+	}
+	if option == nil {
+		return fmt.Errorf("invalid audio encoder configuration: encoding %q not supported (allowed: %s)",
+			configuration.Encoding, strings.Join(encodings, ", "))
+	}
+
+	// 设备没有列出可选值时不限制
+	var failures []string
+	if len(option.BitrateList) > 0 && !containsInt(option.BitrateList, configuration.Bitrate) {
+		failures = append(failures, fmt.Sprintf("bitrate %d not supported by %s (allowed: %s)",
+			configuration.Bitrate, configuration.Encoding, joinInts(option.BitrateList)))
+	}
+	if len(option.SampleRateList) > 0 && !containsInt(option.SampleRateList, configuration.SampleRate) {
+		failures = append(failures, fmt.Sprintf("sample rate %d not supported by %s (allowed: %s)",
+			configuration.SampleRate, configuration.Encoding, joinInts(option.SampleRateList)))
+	}
+
+	if len(failures) > 0 {
+		return errors.New("invalid audio encoder configuration: " + strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+func containsInt(list []int, value int) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func joinInts(list []int) string {
+	var items []string
+	for _, item := range list {
+		items = append(items, strconv.Itoa(item))
+	}
+	return strings.Join(items, ", ")
+}
+
+// 修改音频编码配置(编码格式、码率、采样率)，发送前按设备声明的可选参数检查。
+// forcePersistence为false时设备重启后可能恢复原配置
+func (device *OnvifDevice) SetAudioEncoderConfiguration(configuration AudioEncoderConfiguration, forcePersistence bool) error {
+	if configuration.Token == "" {
+		return errors.New("audio encoder configuration token is empty")
+	}
+
+	options, err := device.GetAudioEncoderConfigurationOptions(configuration.Token, "")
+	if err != nil {
+		return err
+	}
+	if err := validateAudioEncoderConfiguration(configuration, options); err != nil {
+		return err
+	}
+
+	request := SetAudioEncoderConfigurationRequest{
+		Configuration: setAudioEncoderConfiguration{
+			Token:          configuration.Token,
+			Name:           configuration.Name,
+			UseCount:       configuration.UseCount,
+			Encoding:       configuration.Encoding,
+			Bitrate:        configuration.Bitrate,
+			SampleRate:     configuration.SampleRate,
+			Multicast:      configuration.Multicast.toSet(),
+			SessionTimeout: configuration.SessionTimeout,
+		},
+		ForcePersistence: forcePersistence,
+	}
+	if err := device.callMediaMethod("SetAudioEncoderConfiguration", request, &AudioEmptyResponse{}); err != nil {
+		return err
+	}
+
+	// 缓存的媒体文件中包含旧配置
+	device.Profile = nil
+	return nil
+}
+
+// 获取所有音频输出配置
+func (device *OnvifDevice) GetAudioOutputConfigurations() ([]AudioOutputConfiguration, error) {
+	var request AudioOutputConfigurationsRequest
+	response := &AudioOutputConfigurationsResponse{}
+	if err := device.callMediaMethod("GetAudioOutputConfigurations", request, response); err != nil {
+		return nil, err
+	}
+	return response.Configurations, nil
+}
+
+// 获取音频输出配置的可选参数，configurationToken、profileToken为空时不指定
+func (device *OnvifDevice) GetAudioOutputConfigurationOptions(configurationToken, profileToken string) (*AudioOutputConfigurationOptions, error) {
+	request := AudioOutputConfigurationOptionsRequest{
+		ConfigurationToken: configurationToken,
+		ProfileToken:       profileToken,
+	}
+	response := &AudioOutputConfigurationOptionsResponse{}
+	if err := device.callMediaMethod("GetAudioOutputConfigurationOptions", request, response); err != nil {
+		return nil, err
+	}
+	return &response.Options, nil
+}
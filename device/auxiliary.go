@@ -0,0 +1,178 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// 辅助命令，格式为"tt:名称|参数"
+type AuxiliaryCommand string
+
+// ONVIF标准辅助命令。
+// 加热器、除雾等没有标准名称，使用设备在AuxiliaryCommands中上报的厂商命令
+const (
+	AuxiliaryWiperOn             AuxiliaryCommand = "tt:Wiper|On"
+	AuxiliaryWiperOff            AuxiliaryCommand = "tt:Wiper|Off"
+	AuxiliaryWasherOn            AuxiliaryCommand = "tt:Washer|On"
+	AuxiliaryWasherOff           AuxiliaryCommand = "tt:Washer|Off"
+	AuxiliaryWashingProcedureOn  AuxiliaryCommand = "tt:WashingProcedure|On"
+	AuxiliaryWashingProcedureOff AuxiliaryCommand = "tt:WashingProcedure|Off"
+	AuxiliaryIRLampOn            AuxiliaryCommand = "tt:IRLamp|On"
+	AuxiliaryIRLampOff           AuxiliaryCommand = "tt:IRLamp|Off"
+	AuxiliaryIRLampAuto          AuxiliaryCommand = "tt:IRLamp|Auto"
+)
+
+// 云台节点
+type PTZNode struct {
+	Token                  string             `xml:"token,attr"`
+	FixedHomePosition      bool               `xml:"FixedHomePosition,attr"`
+	Name                   string             `xml:"Name"`
+	MaximumNumberOfPresets int                `xml:"MaximumNumberOfPresets"`
+	HomeSupported          bool               `xml:"HomeSupported"`
+	AuxiliaryCommands      []AuxiliaryCommand `xml:"AuxiliaryCommands"`
+}
+
+// 节点是否支持某个辅助命令
+func (node *PTZNode) SupportsAuxiliaryCommand(command AuxiliaryCommand) bool {
+	for _, supported := range node.AuxiliaryCommands {
+		if supported == command {
+			return true
+		}
+	}
+	return false
+}
+
+type NodesRequest struct {
+	XMLName string `xml:"tptz:GetNodes"`
+}
+
+type NodesResponse struct {
+	XMLName string    `xml:"Envelope"`
+	PTZNode []PTZNode `xml:"Body>GetNodesResponse>PTZNode"`
+}
+
+type NodeRequest struct {
+	XMLName   string `xml:"tptz:GetNode"`
+	NodeToken string `xml:"tptz:NodeToken"`
+}
+
+type NodeResponse struct {
+	XMLName string  `xml:"Envelope"`
+	PTZNode PTZNode `xml:"Body>GetNodeResponse>PTZNode"`
+}
+
+type PTZAuxiliaryCommandRequest struct {
+	XMLName       string `xml:"tptz:SendAuxiliaryCommand"`
+	ProfileToken  string `xml:"tptz:ProfileToken"`
+	AuxiliaryData string `xml:"tptz:AuxiliaryData"`
+}
+
+type PTZAuxiliaryCommandResponse struct {
+	XMLName           string `xml:"Envelope"`
+	AuxiliaryResponse string `xml:"Body>SendAuxiliaryCommandResponse>AuxiliaryResponse"`
+}
+
+type AuxiliaryCommandRequest struct {
+	XMLName          string `xml:"tds:SendAuxiliaryCommand"`
+	AuxiliaryCommand string `xml:"tds:AuxiliaryCommand"`
+}
+
+type AuxiliaryCommandResponse struct {
+	XMLName                  string `xml:"Envelope"`
+	AuxiliaryCommandResponse string `xml:"Body>SendAuxiliaryCommandResponse>AuxiliaryCommandResponse"`
+}
+
+// 获取所有云台节点
+func (device *OnvifDevice) GetNodes() ([]PTZNode, error) {
+	ptzAddr, err := device.ptzServiceAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	var request NodesRequest
+	response := &NodesResponse{}
+	err = device.callMethod(ptzAddr, "http://www.onvif.org/ver20/ptz/wsdl/GetNodes", request, response)
+	if err != nil {
+		log.Println("GetNodes fail", err)
+		return nil, err
+	}
+
+	return response.PTZNode, nil
+}
+
+// 获取指定云台节点
+func (device *OnvifDevice) GetNode(nodeToken string) (*PTZNode, error) {
+	ptzAddr, err := device.ptzServiceAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	request := NodeRequest{NodeToken: nodeToken}
+	response := &NodeResponse{}
+	err = device.callMethod(ptzAddr, "http://www.onvif.org/ver20/ptz/wsdl/GetNode", request, response)
+	if err != nil {
+		log.Println("GetNode fail", err)
+		return nil, err
+	}
+
+	return &response.PTZNode, nil
+}
+
+// 获取媒体文件对应云台节点支持的辅助命令
+func (device *OnvifDevice) GetAuxiliaryCommands(profileToken string) ([]AuxiliaryCommand, error) {
+	profile, err := device.findProfile(profileToken)
+	if err != nil {
+		return nil, err
+	}
+	if profile.PTZ.NodeToken == "" {
+		return nil, fmt.Errorf("profile %s has no ptz configuration", profileToken)
+	}
+
+	node, err := device.GetNode(profile.PTZ.NodeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.AuxiliaryCommands, nil
+}
+
+// 通过云台服务发送辅助命令，例如AuxiliaryWiperOn
+func (device *OnvifDevice) SendPTZAuxiliaryCommand(profileToken string, command AuxiliaryCommand) (string, error) {
+	if command == "" {
+		return "", errors.New("auxiliary command is empty")
+	}
+
+	ptzAddr, err := device.ptzServiceAddr()
+	if err != nil {
+		return "", err
+	}
+
+	request := PTZAuxiliaryCommandRequest{ProfileToken: profileToken, AuxiliaryData: string(command)}
+	response := &PTZAuxiliaryCommandResponse{}
+	err = device.callMethod(ptzAddr, "http://www.onvif.org/ver20/ptz/wsdl/SendAuxiliaryCommand", request, response)
+	if err != nil {
+		log.Println("SendPTZAuxiliaryCommand fail", err)
+		return "", err
+	}
+
+	return response.AuxiliaryResponse, nil
+}
+
+// 通过设备管理服务发送辅助命令，用于没有云台的设备
+func (device *OnvifDevice) SendAuxiliaryCommand(command AuxiliaryCommand) (string, error) {
+	if command == "" {
+		return "", errors.New("auxiliary command is empty")
+	}
+
+	request := AuxiliaryCommandRequest{AuxiliaryCommand: string(command)}
+	response := &AuxiliaryCommandResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/SendAuxiliaryCommand", request, response)
+	if err != nil {
+		log.Println("SendAuxiliaryCommand fail", err)
+		return "", err
+	}
+
+	return response.AuxiliaryCommandResponse, nil
+}
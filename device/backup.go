@@ -0,0 +1,272 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 备份文件
+type BackupFile struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+type backupFile struct {
+	Name string         `xml:"Name"`
+	Data AttachmentData `xml:"Data"`
+}
+
+type setBackupFile struct {
+	Name string            `xml:"tt:Name"`
+	Data setAttachmentData `xml:"tt:Data"`
+}
+
+type SystemBackupRequest struct {
+	XMLName string `xml:"tds:GetSystemBackup"`
+}
+
+type SystemBackupResponse struct {
+	XMLName     string       `xml:"Envelope"`
+	BackupFiles []backupFile `xml:"Body>GetSystemBackupResponse>BackupFiles"`
+}
+
+type RestoreSystemRequest struct {
+	XMLName     string          `xml:"tds:RestoreSystem"`
+	BackupFiles []setBackupFile `xml:"tds:BackupFiles"`
+}
+
+type RestoreSystemResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+type StartSystemRestoreRequest struct {
+	XMLName string `xml:"tds:StartSystemRestore"`
+}
+
+type StartSystemRestoreResponse struct {
+	XMLName          string `xml:"Envelope"`
+	UploadUri        string `xml:"Body>StartSystemRestoreResponse>UploadUri"`
+	ExpectedDownTime string `xml:"Body>StartSystemRestoreResponse>ExpectedDownTime"`
+}
+
+// 配置恢复上传信息，上传完成后设备约ExpectedDownTime不可用
+type SystemRestoreInfo struct {
+	UploadUri        string
+	ExpectedDownTime time.Duration
+}
+
+func (device *OnvifDevice) checkSystemBackup() error {
+	if err := device.ensureCapabilities(); err != nil {
+		return err
+	}
+	if !device.Capabilities.Capabilities.Device.SystemBackup {
+		return errors.New("the device do not support system backup")
+	}
+	return nil
+}
+
+// 获取设备配置备份
+func (device *OnvifDevice) GetSystemBackup() ([]BackupFile, error) {
+	if err := device.checkSystemBackup(); err != nil {
+		return nil, err
+	}
+
+	var request SystemBackupRequest
+	response := &SystemBackupResponse{}
+	attachments, err := device.callMethodWithAttachments(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetSystemBackup", request, response)
+	if err != nil {
+		log.Println("GetSystemBackup fail", err)
+		return nil, err
+	}
+
+	var files []BackupFile
+	for _, file := range response.BackupFiles {
+		data, err := file.Data.bytes(attachments)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, BackupFile{
+			Name:        file.Name,
+			ContentType: file.Data.ContentType,
+			Data:        data,
+		})
+	}
+
+	return files, nil
+}
+
+// 获取配置备份并保存到dir目录，每个备份文件按设备给出的文件名保存
+func (device *OnvifDevice) SaveSystemBackup(dir string) ([]BackupFile, error) {
+	files, err := device.GetSystemBackup()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.New("device returned no backup file")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	for i, file := range files {
+		name := filepath.Base(file.Name)
+		if name == "." || name == string(filepath.Separator) {
+			name = fmt.Sprintf("backup%d", i)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), file.Data, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// 读取SaveSystemBackup保存的备份目录
+func ReadBackupFiles(dir string) ([]BackupFile, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []BackupFile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, BackupFile{
+			Name:        entry.Name(),
+			ContentType: "application/octet-stream",
+			Data:        data,
+		})
+	}
+
+	return files, nil
+}
+
+// 以MTOM附件上传备份文件恢复设备配置
+func (device *OnvifDevice) RestoreSystem(files []BackupFile) error {
+	if len(files) == 0 {
+		return errors.New("no backup file to restore")
+	}
+	if err := device.checkSystemBackup(); err != nil {
+		return err
+	}
+
+	var request RestoreSystemRequest
+	var attachments []mtomAttachment
+	for i, file := range files {
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		contentID := fmt.Sprintf("backup%d@onvif", i)
+		request.BackupFiles = append(request.BackupFiles, setBackupFile{
+			Name: file.Name,
+			Data: setAttachmentData{
+				ContentType: contentType,
+				Include:     setXopInclude{Href: "cid:" + contentID},
+			},
+		})
+		attachments = append(attachments, mtomAttachment{
+			ContentID:   contentID,
+			ContentType: contentType,
+			Data:        file.Data,
+		})
+	}
+
+	err := device.callMethodWithMTOM(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/RestoreSystem", request, &RestoreSystemResponse{}, attachments)
+	if err != nil {
+		log.Println("RestoreSystem fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 获取配置恢复的上传地址
+func (device *OnvifDevice) StartSystemRestore() (*SystemRestoreInfo, error) {
+	var request StartSystemRestoreRequest
+	response := &StartSystemRestoreResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/StartSystemRestore", request, response)
+	if err != nil {
+		log.Println("StartSystemRestore fail", err)
+		return nil, err
+	}
+
+	if response.UploadUri == "" {
+		return nil, errors.New("StartSystemRestore returned an empty upload uri")
+	}
+
+	expectedDownTime, err := parseDuration(response.ExpectedDownTime)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SystemRestoreInfo{
+		UploadUri:        response.UploadUri,
+		ExpectedDownTime: expectedDownTime,
+	}, nil
+}
+
+// 通过StartSystemRestore返回的地址上传备份文件
+func (device *OnvifDevice) UploadSystemRestore(info *SystemRestoreInfo, path string, progress UploadProgress) error {
+	if err := device.uploadFile(info.UploadUri, path, progress); err != nil {
+		log.Println("UploadSystemRestore fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 用单个备份文件恢复设备配置，等待设备重启下线后再重新上线。
+// 设备以ActionNotSupported拒绝StartSystemRestore时改用RestoreSystem，其他错误直接返回。
+// timeout为上传完成后等待的总时间，其中最多一半用于等待设备下线，设备未下线说明恢复没有生效，返回错误
+func (device *OnvifDevice) RestoreSystemFromFile(path string, progress UploadProgress, timeout time.Duration) error {
+	if err := device.checkSystemBackup(); err != nil {
+		return err
+	}
+
+	info, err := device.StartSystemRestore()
+	if err == nil {
+		if err := device.UploadSystemRestore(info, path, progress); err != nil {
+			return err
+		}
+	} else if isActionNotSupported(err) {
+		log.Println("StartSystemRestore not supported, fall back to RestoreSystem")
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		err = device.RestoreSystem([]BackupFile{{
+			Name:        filepath.Base(path),
+			ContentType: "application/octet-stream",
+			Data:        data,
+		}})
+		if err != nil {
+			return err
+		}
+	} else {
+		return err
+	}
+
+	// 上传后设备还会继续应答一段时间，不等设备下线会把恢复前的设备当作已上线
+	start := time.Now()
+	if !device.waitUntilDown(timeout / 2) {
+		return fmt.Errorf("device %s did not go down within %v after restore", device.DeviceIp, timeout/2)
+	}
+
+	return device.WaitUntilReady(timeout - time.Since(start))
+}
@@ -0,0 +1,316 @@
+package device
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// 设备证书，Data为DER编码
+type NvtCertificate struct {
+	CertificateID string
+	Data          []byte
+}
+
+// 解析证书内容
+func (certificate *NvtCertificate) Parse() (*x509.Certificate, error) {
+	return x509.ParseCertificate(certificate.Data)
+}
+
+type nvtCertificate struct {
+	CertificateID string `xml:"CertificateID"`
+	Data          string `xml:"Certificate>Data"`
+}
+
+func (certificate *nvtCertificate) decode() (NvtCertificate, error) {
+	data, err := decodeBase64(certificate.Data)
+	if err != nil {
+		return NvtCertificate{}, fmt.Errorf("certificate %s: %v", certificate.CertificateID, err)
+	}
+	return NvtCertificate{CertificateID: certificate.CertificateID, Data: data}, nil
+}
+
+type setNvtCertificate struct {
+	CertificateID string `xml:"tt:CertificateID"`
+	Data          string `xml:"tt:Certificate>tt:Data"`
+}
+
+// 证书启用状态
+type CertificateStatus struct {
+	CertificateID string `xml:"CertificateID"`
+	Status        bool   `xml:"Status"`
+}
+
+type setCertificateStatus struct {
+	CertificateID string `xml:"tt:CertificateID"`
+	Status        bool   `xml:"tt:Status"`
+}
+
+// 解码base64，忽略设备在数据中插入的换行和空格
+func decodeBase64(data string) ([]byte, error) {
+	data = strings.Join(strings.Fields(data), "")
+	return base64.StdEncoding.DecodeString(data)
+}
+
+type CreateCertificateRequest struct {
+	XMLName        string `xml:"tds:CreateCertificate"`
+	CertificateID  string `xml:"tds:CertificateID,omitempty"`
+	Subject        string `xml:"tds:Subject,omitempty"`
+	ValidNotBefore string `xml:"tds:ValidNotBefore,omitempty"`
+	ValidNotAfter  string `xml:"tds:ValidNotAfter,omitempty"`
+}
+
+type CreateCertificateResponse struct {
+	XMLName        string         `xml:"Envelope"`
+	NvtCertificate nvtCertificate `xml:"Body>CreateCertificateResponse>NvtCertificate"`
+}
+
+type CertificatesRequest struct {
+	XMLName string `xml:"tds:GetCertificates"`
+}
+
+type CertificatesResponse struct {
+	XMLName        string           `xml:"Envelope"`
+	NvtCertificate []nvtCertificate `xml:"Body>GetCertificatesResponse>NvtCertificate"`
+}
+
+type CertificatesStatusRequest struct {
+	XMLName string `xml:"tds:GetCertificatesStatus"`
+}
+
+type CertificatesStatusResponse struct {
+	XMLName           string              `xml:"Envelope"`
+	CertificateStatus []CertificateStatus `xml:"Body>GetCertificatesStatusResponse>CertificateStatus"`
+}
+
+type SetCertificatesStatusRequest struct {
+	XMLName           string                 `xml:"tds:SetCertificatesStatus"`
+	CertificateStatus []setCertificateStatus `xml:"tds:CertificateStatus"`
+}
+
+type LoadCertificatesRequest struct {
+	XMLName        string              `xml:"tds:LoadCertificates"`
+	NVTCertificate []setNvtCertificate `xml:"tds:NVTCertificate"`
+}
+
+type DeleteCertificatesRequest struct {
+	XMLName       string   `xml:"tds:DeleteCertificates"`
+	CertificateID []string `xml:"tds:CertificateID"`
+}
+
+type Pkcs10RequestRequest struct {
+	XMLName       string `xml:"tds:GetPkcs10Request"`
+	CertificateID string `xml:"tds:CertificateID"`
+	Subject       string `xml:"tds:Subject,omitempty"`
+}
+
+type Pkcs10RequestResponse struct {
+	XMLName string `xml:"Envelope"`
+	Data    string `xml:"Body>GetPkcs10RequestResponse>Pkcs10Request>Data"`
+}
+
+type CertificateEmptyResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+// 由设备生成密钥对和自签名证书。certificateID、subject为空时由设备决定，
+// validNotBefore、validNotAfter为零值时不指定
+func (device *OnvifDevice) CreateCertificate(certificateID, subject string, validNotBefore, validNotAfter time.Time) (*NvtCertificate, error) {
+	request := CreateCertificateRequest{
+		CertificateID: certificateID,
+		Subject:       subject,
+	}
+	if !validNotBefore.IsZero() {
+		request.ValidNotBefore = validNotBefore.UTC().Format(time.RFC3339)
+	}
+	if !validNotAfter.IsZero() {
+		request.ValidNotAfter = validNotAfter.UTC().Format(time.RFC3339)
+	}
+
+	response := &CreateCertificateResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/CreateCertificate", request, response)
+	if err != nil {
+		log.Println("CreateCertificate fail", err)
+		return nil, err
+	}
+
+	certificate, err := response.NvtCertificate.decode()
+	if err != nil {
+		return nil, err
+	}
+	return &certificate, nil
+}
+
+// 获取设备上的证书
+func (device *OnvifDevice) GetCertificates() ([]NvtCertificate, error) {
+	var request CertificatesRequest
+	response := &CertificatesResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetCertificates", request, response)
+	if err != nil {
+		log.Println("GetCertificates fail", err)
+		return nil, err
+	}
+
+	var certificates []NvtCertificate
+	for _, raw := range response.NvtCertificate {
+		certificate, err := raw.decode()
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+
+	return certificates, nil
+}
+
+// 获取证书启用状态
+func (device *OnvifDevice) GetCertificatesStatus() ([]CertificateStatus, error) {
+	var request CertificatesStatusRequest
+	response := &CertificatesStatusResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetCertificatesStatus", request, response)
+	if err != nil {
+		log.Println("GetCertificatesStatus fail", err)
+		return nil, err
+	}
+
+	return response.CertificateStatus, nil
+}
+
+// 设置证书启用状态，HTTPS使用启用的证书
+func (device *OnvifDevice) SetCertificatesStatus(status []CertificateStatus) error {
+	if len(status) == 0 {
+		return errors.New("no certificate status to set")
+	}
+
+	var request SetCertificatesStatusRequest
+	for _, item := range status {
+		request.CertificateStatus = append(request.CertificateStatus, setCertificateStatus{
+			CertificateID: item.CertificateID,
+			Status:        item.Status,
+		})
+	}
+
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/SetCertificatesStatus", request, &CertificateEmptyResponse{})
+	if err != nil {
+		log.Println("SetCertificatesStatus fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 上传证书，证书的私钥必须已在设备上(由CreateCertificate生成)
+func (device *OnvifDevice) LoadCertificates(certificates []NvtCertificate) error {
+	if len(certificates) == 0 {
+		return errors.New("no certificate to load")
+	}
+
+	var request LoadCertificatesRequest
+	for _, certificate := range certificates {
+		request.NVTCertificate = append(request.NVTCertificate, setNvtCertificate{
+			CertificateID: certificate.CertificateID,
+			Data:          base64.StdEncoding.EncodeToString(certificate.Data),
+		})
+	}
+
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/LoadCertificates", request, &CertificateEmptyResponse{})
+	if err != nil {
+		log.Println("LoadCertificates fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 删除证书
+func (device *OnvifDevice) DeleteCertificates(certificateIDs []string) error {
+	if len(certificateIDs) == 0 {
+		return errors.New("no certificate to delete")
+	}
+
+	request := DeleteCertificatesRequest{CertificateID: certificateIDs}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/DeleteCertificates", request, &CertificateEmptyResponse{})
+	if err != nil {
+		log.Println("DeleteCertificates fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 获取证书对应的PKCS#10证书请求(DER编码)，subject为空时使用证书原有主题
+func (device *OnvifDevice) GetPkcs10Request(certificateID, subject string) (*x509.CertificateRequest, error) {
+	request := Pkcs10RequestRequest{
+		CertificateID: certificateID,
+		Subject:       subject,
+	}
+	response := &Pkcs10RequestResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetPkcs10Request", request, response)
+	if err != nil {
+		log.Println("GetPkcs10Request fail", err)
+		return nil, err
+	}
+
+	data, err := decodeBase64(response.Data)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificateRequest(data)
+}
+
+// 用内部CA签发设备证书并启用：设备生成密钥，取得证书请求交给sign签名，
+// 上传签名后的证书(DER编码)并将其设为唯一启用的证书
+func (device *OnvifDevice) InstallSignedCertificate(certificateID, subject string,
+	sign func(csr *x509.CertificateRequest) ([]byte, error)) error {
+	if certificateID == "" {
+		return errors.New("certificate id is empty")
+	}
+
+	if _, err := device.CreateCertificate(certificateID, subject, time.Time{}, time.Time{}); err != nil {
+		return err
+	}
+
+	csr, err := device.GetPkcs10Request(certificateID, subject)
+	if err != nil {
+		return err
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return fmt.Errorf("invalid certificate request from device: %v", err)
+	}
+
+	signed, err := sign(csr)
+	if err != nil {
+		return err
+	}
+
+	err = device.LoadCertificates([]NvtCertificate{{CertificateID: certificateID, Data: signed}})
+	if err != nil {
+		return err
+	}
+
+	statuses, err := device.GetCertificatesStatus()
+	if err != nil {
+		return err
+	}
+	found := false
+	for i := range statuses {
+		statuses[i].Status = statuses[i].CertificateID == certificateID
+		found = found || statuses[i].Status
+	}
+	if !found {
+		statuses = append(statuses, CertificateStatus{CertificateID: certificateID, Status: true})
+	}
+
+	return device.SetCertificatesStatus(statuses)
+}
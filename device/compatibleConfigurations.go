@@ -0,0 +1,299 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+type CompatibleVideoSourceConfigurationsRequest struct {
+	XMLName      string `xml:"trt:GetCompatibleVideoSourceConfigurations"`
+	ProfileToken string `xml:"trt:ProfileToken"`
+}
+
+type CompatibleVideoSourceConfigurationsResponse struct {
+	XMLName        string                     `xml:"Envelope"`
+	Configurations []VideoSourceConfiguration `xml:"Body>GetCompatibleVideoSourceConfigurationsResponse>Configurations"`
+}
+
+type CompatibleVideoEncoderConfigurationsRequest struct {
+	XMLName      string `xml:"trt:GetCompatibleVideoEncoderConfigurations"`
+	ProfileToken string `xml:"trt:ProfileToken"`
+}
+
+type CompatibleVideoEncoderConfigurationsResponse struct {
+	XMLName        string                      `xml:"Envelope"`
+	Configurations []VideoEncoderConfiguration `xml:"Body>GetCompatibleVideoEncoderConfigurationsResponse>Configurations"`
+}
+
+type CompatibleAudioSourceConfigurationsRequest struct {
+	XMLName      string `xml:"trt:GetCompatibleAudioSourceConfigurations"`
+	ProfileToken string `xml:"trt:ProfileToken"`
+}
+
+type CompatibleAudioSourceConfigurationsResponse struct {
+	XMLName        string                     `xml:"Envelope"`
+	Configurations []AudioSourceConfiguration `xml:"Body>GetCompatibleAudioSourceConfigurationsResponse>Configurations"`
+}
+
+type CompatibleAudioEncoderConfigurationsRequest struct {
+	XMLName      string `xml:"trt:GetCompatibleAudioEncoderConfigurations"`
+	ProfileToken string `xml:"trt:ProfileToken"`
+}
+
+type CompatibleAudioEncoderConfigurationsResponse struct {
+	XMLName        string                      `xml:"Envelope"`
+	Configurations []AudioEncoderConfiguration `xml:"Body>GetCompatibleAudioEncoderConfigurationsResponse>Configurations"`
+}
+
+type CompatibleVideoAnalyticsConfigurationsRequest struct {
+	XMLName      string `xml:"trt:GetCompatibleVideoAnalyticsConfigurations"`
+	ProfileToken string `xml:"trt:ProfileToken"`
+}
+
+type CompatibleVideoAnalyticsConfigurationsResponse struct {
+	XMLName        string                        `xml:"Envelope"`
+	Configurations []VideoAnalyticsConfiguration `xml:"Body>GetCompatibleVideoAnalyticsConfigurationsResponse>Configurations"`
+}
+
+type CompatibleMetadataConfigurationsRequest struct {
+	XMLName      string `xml:"trt:GetCompatibleMetadataConfigurations"`
+	ProfileToken string `xml:"trt:ProfileToken"`
+}
+
+type CompatibleMetadataConfigurationsResponse struct {
+	XMLName        string                  `xml:"Envelope"`
+	Configurations []MetadataConfiguration `xml:"Body>GetCompatibleMetadataConfigurationsResponse>Configurations"`
+}
+
+type CompatiblePTZConfigurationsRequest struct {
+	XMLName      string `xml:"tptz:GetCompatibleConfigurations"`
+	ProfileToken string `xml:"tptz:ProfileToken"`
+}
+
+type CompatiblePTZConfigurationsResponse struct {
+	XMLName          string             `xml:"Envelope"`
+	PTZConfiguration []PTZConfiguration `xml:"Body>GetCompatibleConfigurationsResponse>PTZConfiguration"`
+}
+
+// 获取可以添加到媒体文件的视频源配置
+func (device *OnvifDevice) GetCompatibleVideoSourceConfigurations(profileToken string) ([]VideoSourceConfiguration, error) {
+	request := CompatibleVideoSourceConfigurationsRequest{ProfileToken: profileToken}
+	response := &CompatibleVideoSourceConfigurationsResponse{}
+	if err := device.callMediaMethod("GetCompatibleVideoSourceConfigurations", request, response); err != nil {
+		return nil, err
+	}
+	return response.Configurations, nil
+}
+
+// 获取可以添加到媒体文件的视频编码配置，媒体文件需要已有视频源配置
+func (device *OnvifDevice) GetCompatibleVideoEncoderConfigurations(profileToken string) ([]VideoEncoderConfiguration, error) {
+	request := CompatibleVideoEncoderConfigurationsRequest{ProfileToken: profileToken}
+	response := &CompatibleVideoEncoderConfigurationsResponse{}
+	if err := device.callMediaMethod("GetCompatibleVideoEncoderConfigurations", request, response); err != nil {
+		return nil, err
+	}
+	return response.Configurations, nil
+}
+
+// 获取可以添加到媒体文件的音频源配置
+func (device *OnvifDevice) GetCompatibleAudioSourceConfigurations(profileToken string) ([]AudioSourceConfiguration, error) {
+	request := CompatibleAudioSourceConfigurationsRequest{ProfileToken: profileToken}
+	response := &CompatibleAudioSourceConfigurationsResponse{}
+	if err := device.callMediaMethod("GetCompatibleAudioSourceConfigurations", request, response); err != nil {
+		return nil, err
+	}
+	return response.Configurations, nil
+}
+
+// 获取可以添加到媒体文件的音频编码配置，媒体文件需要已有音频源配置
+func (device *OnvifDevice) GetCompatibleAudioEncoderConfigurations(profileToken string) ([]AudioEncoderConfiguration, error) {
+	request := CompatibleAudioEncoderConfigurationsRequest{ProfileToken: profileToken}
+	response := &CompatibleAudioEncoderConfigurationsResponse{}
+	if err := device.callMediaMethod("GetCompatibleAudioEncoderConfigurations", request, response); err != nil {
+		return nil, err
+	}
+	return response.Configurations, nil
+}
+
+// 获取可以添加到媒体文件的视频分析配置
+func (device *OnvifDevice) GetCompatibleVideoAnalyticsConfigurations(profileToken string) ([]VideoAnalyticsConfiguration, error) {
+	request := CompatibleVideoAnalyticsConfigurationsRequest{ProfileToken: profileToken}
+	response := &CompatibleVideoAnalyticsConfigurationsResponse{}
+	if err := device.callMediaMethod("GetCompatibleVideoAnalyticsConfigurations", request, response); err != nil {
+		return nil, err
+	}
+	return response.Configurations, nil
+}
+
+// 获取可以添加到媒体文件的元数据配置
+func (device *OnvifDevice) GetCompatibleMetadataConfigurations(profileToken string) ([]MetadataConfiguration, error) {
+	request := CompatibleMetadataConfigurationsRequest{ProfileToken: profileToken}
+	response := &CompatibleMetadataConfigurationsResponse{}
+	if err := device.callMediaMethod("GetCompatibleMetadataConfigurations", request, response); err != nil {
+		return nil, err
+	}
+	return response.Configurations, nil
+}
+
+// 获取可以添加到媒体文件的云台配置，由云台服务提供
+func (device *OnvifDevice) GetCompatiblePTZConfigurations(profileToken string) ([]PTZConfiguration, error) {
+	ptzAddr, err := device.ptzServiceAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	request := CompatiblePTZConfigurationsRequest{ProfileToken: profileToken}
+	response := &CompatiblePTZConfigurationsResponse{}
+	err = device.callMethod(ptzAddr, "http://www.onvif.org/ver20/ptz/wsdl/GetCompatibleConfigurations", request, response)
+	if err != nil {
+		log.Println("GetCompatibleConfigurations fail", err)
+		return nil, err
+	}
+
+	return response.PTZConfiguration, nil
+}
+
+// 把值限制在取值范围内，设备未声明的范围(Max为0)不限制
+func (r IntRange) clamp(value int) int {
+	if r.Max == 0 {
+		return value
+	}
+	if value < r.Min {
+		return r.Min
+	}
+	if value > r.Max {
+		return r.Max
+	}
+	return value
+}
+
+// 把编码配置改为指定的编码格式和分辨率，其余参数调整到设备允许的范围内
+func fitVideoEncoderConfiguration(configuration VideoEncoderConfiguration, options *VideoEncoderConfigurationOptions,
+	encoding string, resolution Resolution) (VideoEncoderConfiguration, error) {
+	encoder, err := options.Encoder(encoding)
+	if err != nil {
+		return configuration, err
+	}
+
+	supported := false
+	for _, available := range encoder.ResolutionsAvailable {
+		supported = supported || available == resolution
+	}
+	if !supported {
+		return configuration, fmt.Errorf("resolution %s not supported by %s", resolution, encoding)
+	}
+
+	configuration.Encoding = encoding
+	configuration.Resolution = resolution
+
+	quality, err := strconv.ParseFloat(configuration.Quality, 64)
+	if err != nil {
+		quality = float64(options.QualityRange.Max)
+	}
+	if options.QualityRange.Max > 0 {
+		if quality < float64(options.QualityRange.Min) {
+			quality = float64(options.QualityRange.Min)
+		}
+		if quality > float64(options.QualityRange.Max) {
+			quality = float64(options.QualityRange.Max)
+		}
+	}
+	configuration.Quality = strconv.FormatFloat(quality, 'f', -1, 64)
+
+	rateControl := &configuration.RateControl
+	rateControl.FrameRateLimit = encoder.FrameRateRange.clamp(rateControl.FrameRateLimit)
+	rateControl.EncodingInterval = encoder.EncodingIntervalRange.clamp(rateControl.EncodingInterval)
+	rateControl.BitrateLimit = encoder.BitrateRange.clamp(rateControl.BitrateLimit)
+
+	switch encoding {
+	case VideoEncodingMPEG4:
+		configuration.MPEG4.GovLength = encoder.GovLengthRange.clamp(configuration.MPEG4.GovLength)
+		// 设备没有列出支持的档次时不限制
+		if len(encoder.Mpeg4ProfilesSupported) > 0 && !containsString(encoder.Mpeg4ProfilesSupported, configuration.MPEG4.Mpeg4Profile) {
+			configuration.MPEG4.Mpeg4Profile = encoder.Mpeg4ProfilesSupported[0]
+		}
+	case VideoEncodingH264:
+		configuration.H264.GovLength = encoder.GovLengthRange.clamp(configuration.H264.GovLength)
+		if len(encoder.H264ProfilesSupported) > 0 && !containsString(encoder.H264ProfilesSupported, configuration.H264.H264Profile) {
+			configuration.H264.H264Profile = encoder.H264ProfilesSupported[0]
+		}
+	}
+
+	return configuration, nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// 按需要的编码格式和分辨率自动组建媒体文件：创建媒体文件，添加第一个兼容的视频源配置，
+// 优先使用编码格式和分辨率完全一致的视频编码配置，否则选一个未被其他媒体文件使用的
+// 兼容编码配置并修改为需要的参数。任何一步失败都会删除新建的媒体文件
+func (device *OnvifDevice) BuildProfile(name, encoding string, resolution Resolution) (*Profile, error) {
+	profile, err := device.CreateProfile(name, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := device.composeProfile(profile.Token, encoding, resolution); err != nil {
+		if deleteErr := device.DeleteProfile(profile.Token); deleteErr != nil {
+			log.Println("BuildProfile: delete profile fail", deleteErr)
+		}
+		return nil, err
+	}
+
+	return device.findProfile(profile.Token)
+}
+
+func (device *OnvifDevice) composeProfile(profileToken, encoding string, resolution Resolution) error {
+	sources, err := device.GetCompatibleVideoSourceConfigurations(profileToken)
+	if err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		return errors.New("no compatible video source configuration")
+	}
+	if err := device.AddVideoSourceConfiguration(profileToken, sources[0].Token); err != nil {
+		return err
+	}
+
+	encoders, err := device.GetCompatibleVideoEncoderConfigurations(profileToken)
+	if err != nil {
+		return err
+	}
+	for _, encoder := range encoders {
+		if encoder.Encoding == encoding && encoder.Resolution == resolution {
+			return device.AddVideoEncoderConfiguration(profileToken, encoder.Token)
+		}
+	}
+
+	// 修改正在被其他媒体文件使用的编码配置会影响这些媒体文件的码流
+	for _, encoder := range encoders {
+		if encoder.UseCount > 0 {
+			continue
+		}
+
+		options, err := device.GetVideoEncoderConfigurationOptions(encoder.Token, profileToken)
+		if err != nil {
+			return err
+		}
+		fitted, err := fitVideoEncoderConfiguration(encoder, options, encoding, resolution)
+		if err != nil {
+			continue
+		}
+
+		if err := device.AddVideoEncoderConfiguration(profileToken, encoder.Token); err != nil {
+			return err
+		}
+		return device.SetVideoEncoderConfiguration(fitted, true)
+	}
+
+	return fmt.Errorf("no unused video encoder configuration supports %s %s", encoding, resolution)
+}
@@ -0,0 +1,313 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// 发现模式
+const (
+	DiscoveryModeDiscoverable    = "Discoverable"
+	DiscoveryModeNonDiscoverable = "NonDiscoverable"
+)
+
+// 动态DNS类型
+const (
+	DynamicDNSNoUpdate      = "NoUpdate"
+	DynamicDNSClientUpdates = "ClientUpdates"
+	DynamicDNSServerUpdates = "ServerUpdates"
+)
+
+type DiscoveryModeRequest struct {
+	XMLName string `xml:"tds:GetDiscoveryMode"`
+}
+
+type DiscoveryModeResponse struct {
+	XMLName       string `xml:"Envelope"`
+	DiscoveryMode string `xml:"Body>GetDiscoveryModeResponse>DiscoveryMode"`
+}
+
+type SetDiscoveryModeRequest struct {
+	XMLName       string `xml:"tds:SetDiscoveryMode"`
+	DiscoveryMode string `xml:"tds:DiscoveryMode"`
+}
+
+type RemoteDiscoveryModeRequest struct {
+	XMLName string `xml:"tds:GetRemoteDiscoveryMode"`
+}
+
+type RemoteDiscoveryModeResponse struct {
+	XMLName             string `xml:"Envelope"`
+	RemoteDiscoveryMode string `xml:"Body>GetRemoteDiscoveryModeResponse>RemoteDiscoveryMode"`
+}
+
+type SetRemoteDiscoveryModeRequest struct {
+	XMLName             string `xml:"tds:SetRemoteDiscoveryMode"`
+	RemoteDiscoveryMode string `xml:"tds:RemoteDiscoveryMode"`
+}
+
+// 零配置(链路本地地址)设置
+type ZeroConfiguration struct {
+	InterfaceToken string   `xml:"InterfaceToken"`
+	Enabled        bool     `xml:"Enabled"`
+	Addresses      []string `xml:"Addresses"`
+}
+
+type ZeroConfigurationRequest struct {
+	XMLName string `xml:"tds:GetZeroConfiguration"`
+}
+
+type ZeroConfigurationResponse struct {
+	XMLName           string                `xml:"Envelope"`
+	ZeroConfiguration zeroConfigurationList `xml:"Body>GetZeroConfigurationResponse>ZeroConfiguration"`
+}
+
+// 第一个网卡的设置在外层，其余网卡的设置在Extension>Additional中
+type zeroConfigurationList struct {
+	ZeroConfiguration
+	Additional []ZeroConfiguration `xml:"Extension>Additional"`
+}
+
+type SetZeroConfigurationRequest struct {
+	XMLName        string `xml:"tds:SetZeroConfiguration"`
+	InterfaceToken string `xml:"tds:InterfaceToken"`
+	Enabled        bool   `xml:"tds:Enabled"`
+}
+
+// 动态DNS设置
+type DynamicDNSInformation struct {
+	Type string
+	Name string
+	TTL  time.Duration
+}
+
+type DynamicDNSRequest struct {
+	XMLName string `xml:"tds:GetDynamicDNS"`
+}
+
+type DynamicDNSResponse struct {
+	XMLName string `xml:"Envelope"`
+	Type    string `xml:"Body>GetDynamicDNSResponse>DynamicDNSInformation>Type"`
+	Name    string `xml:"Body>GetDynamicDNSResponse>DynamicDNSInformation>Name"`
+	TTL     string `xml:"Body>GetDynamicDNSResponse>DynamicDNSInformation>TTL"`
+}
+
+type SetDynamicDNSRequest struct {
+	XMLName string `xml:"tds:SetDynamicDNS"`
+	Type    string `xml:"tds:Type"`
+	Name    string `xml:"tds:Name,omitempty"`
+	TTL     string `xml:"tds:TTL,omitempty"`
+}
+
+type DiscoveryEmptyResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+func checkDiscoveryMode(mode string) error {
+	if mode != DiscoveryModeDiscoverable && mode != DiscoveryModeNonDiscoverable {
+		return errors.New("discovery mode must be Discoverable or NonDiscoverable")
+	}
+	return nil
+}
+
+// 获取WS-Discovery发现模式
+func (device *OnvifDevice) GetDiscoveryMode() (string, error) {
+	var request DiscoveryModeRequest
+	response := &DiscoveryModeResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetDiscoveryMode", request, response)
+	if err != nil {
+		log.Println("GetDiscoveryMode fail", err)
+		return "", err
+	}
+
+	return response.DiscoveryMode, nil
+}
+
+// 设置WS-Discovery发现模式
+func (device *OnvifDevice) SetDiscoveryMode(mode string) error {
+	if err := checkDiscoveryMode(mode); err != nil {
+		return err
+	}
+
+	request := SetDiscoveryModeRequest{DiscoveryMode: mode}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/SetDiscoveryMode", request, &DiscoveryEmptyResponse{})
+	if err != nil {
+		log.Println("SetDiscoveryMode fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 获取远程发现模式
+func (device *OnvifDevice) GetRemoteDiscoveryMode() (string, error) {
+	var request RemoteDiscoveryModeRequest
+	response := &RemoteDiscoveryModeResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetRemoteDiscoveryMode", request, response)
+	if err != nil {
+		log.Println("GetRemoteDiscoveryMode fail", err)
+		return "", err
+	}
+
+	return response.RemoteDiscoveryMode, nil
+}
+
+// 设置远程发现模式
+func (device *OnvifDevice) SetRemoteDiscoveryMode(mode string) error {
+	if err := checkDiscoveryMode(mode); err != nil {
+		return err
+	}
+
+	request := SetRemoteDiscoveryModeRequest{RemoteDiscoveryMode: mode}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/SetRemoteDiscoveryMode", request, &DiscoveryEmptyResponse{})
+	if err != nil {
+		log.Println("SetRemoteDiscoveryMode fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 获取所有网卡的零配置设置
+func (device *OnvifDevice) GetZeroConfiguration() ([]ZeroConfiguration, error) {
+	var request ZeroConfigurationRequest
+	response := &ZeroConfigurationResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetZeroConfiguration", request, response)
+	if err != nil {
+		log.Println("GetZeroConfiguration fail", err)
+		return nil, err
+	}
+
+	list := response.ZeroConfiguration
+	return append([]ZeroConfiguration{list.ZeroConfiguration}, list.Additional...), nil
+}
+
+// 开启或关闭指定网卡的零配置
+func (device *OnvifDevice) SetZeroConfiguration(interfaceToken string, enabled bool) error {
+	if interfaceToken == "" {
+		return errors.New("interface token is empty")
+	}
+
+	request := SetZeroConfigurationRequest{InterfaceToken: interfaceToken, Enabled: enabled}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/SetZeroConfiguration", request, &DiscoveryEmptyResponse{})
+	if err != nil {
+		log.Println("SetZeroConfiguration fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 获取动态DNS设置
+func (device *OnvifDevice) GetDynamicDNS() (*DynamicDNSInformation, error) {
+	var request DynamicDNSRequest
+	response := &DynamicDNSResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetDynamicDNS", request, response)
+	if err != nil {
+		log.Println("GetDynamicDNS fail", err)
+		return nil, err
+	}
+
+	ttl, err := parseDuration(response.TTL)
+	if err != nil {
+		return nil, err
+	}
+	return &DynamicDNSInformation{Type: response.Type, Name: response.Name, TTL: ttl}, nil
+}
+
+// 设置动态DNS，TTL为0时不指定
+func (device *OnvifDevice) SetDynamicDNS(information DynamicDNSInformation) error {
+	switch information.Type {
+	case DynamicDNSNoUpdate, DynamicDNSClientUpdates, DynamicDNSServerUpdates:
+	default:
+		return errors.New("dynamic dns type must be NoUpdate, ClientUpdates or ServerUpdates")
+	}
+
+	request := SetDynamicDNSRequest{Type: information.Type, Name: information.Name}
+	if information.TTL > 0 {
+		request.TTL = formatDuration(information.TTL)
+	}
+
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/SetDynamicDNS", request, &DiscoveryEmptyResponse{})
+	if err != nil {
+		log.Println("SetDynamicDNS fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 安全加固：关闭WS-Discovery、远程发现和所有网卡的零配置，然后重新读取设置确认生效。
+// 设备能力集中不支持的项会跳过，所有失败项汇总在返回的错误中
+func (device *OnvifDevice) DisableDiscovery() error {
+	if err := device.ensureCapabilities(); err != nil {
+		return err
+	}
+	capabilities := device.Capabilities.Capabilities.Device
+
+	var failures []string
+	fail := func(format string, args ...interface{}) {
+		failures = append(failures, fmt.Sprintf(format, args...))
+	}
+
+	if err := device.SetDiscoveryMode(DiscoveryModeNonDiscoverable); err != nil {
+		fail("SetDiscoveryMode: %v", err)
+	} else if mode, err := device.GetDiscoveryMode(); err != nil {
+		fail("GetDiscoveryMode: %v", err)
+	} else if mode != DiscoveryModeNonDiscoverable {
+		fail("discovery mode is still %s", mode)
+	}
+
+	if capabilities.RemoteDiscovery {
+		if err := device.SetRemoteDiscoveryMode(DiscoveryModeNonDiscoverable); err != nil {
+			fail("SetRemoteDiscoveryMode: %v", err)
+		} else if mode, err := device.GetRemoteDiscoveryMode(); err != nil {
+			fail("GetRemoteDiscoveryMode: %v", err)
+		} else if mode != DiscoveryModeNonDiscoverable {
+			fail("remote discovery mode is still %s", mode)
+		}
+	}
+
+	if capabilities.ZeroConfiguration {
+		configurations, err := device.GetZeroConfiguration()
+		if err != nil {
+			fail("GetZeroConfiguration: %v", err)
+		}
+		for _, configuration := range configurations {
+			if !configuration.Enabled || configuration.InterfaceToken == "" {
+				continue
+			}
+			if err := device.SetZeroConfiguration(configuration.InterfaceToken, false); err != nil {
+				fail("SetZeroConfiguration %s: %v", configuration.InterfaceToken, err)
+			}
+		}
+
+		if err == nil {
+			configurations, err = device.GetZeroConfiguration()
+			if err != nil {
+				fail("GetZeroConfiguration: %v", err)
+			}
+			for _, configuration := range configurations {
+				if configuration.Enabled {
+					fail("zero configuration still enabled on %s", configuration.InterfaceToken)
+				}
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	return nil
+}
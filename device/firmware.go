@@ -0,0 +1,243 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// 上传进度回调，sent为已发送字节数，total为文件总字节数
+type UploadProgress func(sent, total int64)
+
+type StartFirmwareUpgradeRequest struct {
+	XMLName string `xml:"tds:StartFirmwareUpgrade"`
+}
+
+type StartFirmwareUpgradeResponse struct {
+	XMLName          string `xml:"Envelope"`
+	UploadUri        string `xml:"Body>StartFirmwareUpgradeResponse>UploadUri"`
+	UploadDelay      string `xml:"Body>StartFirmwareUpgradeResponse>UploadDelay"`
+	ExpectedDownTime string `xml:"Body>StartFirmwareUpgradeResponse>ExpectedDownTime"`
+}
+
+// 固件升级上传信息，UploadDelay后才能开始上传，上传完成后设备约ExpectedDownTime不可用
+type FirmwareUpgradeInfo struct {
+	UploadUri        string
+	UploadDelay      time.Duration
+	ExpectedDownTime time.Duration
+}
+
+type UpgradeSystemFirmwareRequest struct {
+	XMLName  string            `xml:"tds:UpgradeSystemFirmware"`
+	Firmware setAttachmentData `xml:"tds:Firmware"`
+}
+
+type UpgradeSystemFirmwareResponse struct {
+	XMLName string `xml:"Envelope"`
+	Message string `xml:"Body>UpgradeSystemFirmwareResponse>Message"`
+}
+
+// 计数上传字节并回调进度
+type progressReader struct {
+	reader   io.Reader
+	sent     int64
+	total    int64
+	progress UploadProgress
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.sent += int64(n)
+		if r.progress != nil {
+			r.progress(r.sent, r.total)
+		}
+	}
+	return n, err
+}
+
+// 获取固件上传地址和等待时间
+func (device *OnvifDevice) StartFirmwareUpgrade() (*FirmwareUpgradeInfo, error) {
+	var request StartFirmwareUpgradeRequest
+	response := &StartFirmwareUpgradeResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/StartFirmwareUpgrade", request, response)
+	if err != nil {
+		log.Println("StartFirmwareUpgrade fail", err)
+		return nil, err
+	}
+
+	if response.UploadUri == "" {
+		return nil, errors.New("StartFirmwareUpgrade returned an empty upload uri")
+	}
+
+	uploadDelay, err := parseDuration(response.UploadDelay)
+	if err != nil {
+		return nil, err
+	}
+	expectedDownTime, err := parseDuration(response.ExpectedDownTime)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FirmwareUpgradeInfo{
+		UploadUri:        response.UploadUri,
+		UploadDelay:      uploadDelay,
+		ExpectedDownTime: expectedDownTime,
+	}, nil
+}
+
+// 以HTTP POST上传文件到uploadUri，401时按设备要求的Basic或Digest认证重新上传，进度会从0重新开始
+func (device *OnvifDevice) uploadFile(uploadUri, path string, progress UploadProgress) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{}
+	upload := func(authorization string) (*http.Response, error) {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		body := &progressReader{reader: file, total: info.Size(), progress: progress}
+		req, err := http.NewRequest("POST", uploadUri, body)
+		if err != nil {
+			return nil, err
+		}
+		req.ContentLength = info.Size()
+		req.Header.Set("Content-Type", "application/octet-stream")
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		return client.Do(req)
+	}
+
+	resp, err := upload("")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		authorization := device.httpAuthorization(resp, "POST", resp.Request.URL.RequestURI())
+		if authorization == "" {
+			return errors.New("upload requires authentication but gave no supported challenge")
+		}
+
+		resp, err = upload(authorization)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("upload %s: unexpected status code %d", path, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// 通过StartFirmwareUpgrade返回的地址上传固件
+func (device *OnvifDevice) UploadFirmware(info *FirmwareUpgradeInfo, path string, progress UploadProgress) error {
+	if info.UploadDelay > 0 {
+		time.Sleep(info.UploadDelay)
+	}
+
+	if err := device.uploadFile(info.UploadUri, path, progress); err != nil {
+		log.Println("UploadFirmware fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 旧版固件升级接口，以MTOM附件发送固件，返回设备给出的提示信息
+func (device *OnvifDevice) UpgradeSystemFirmware(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	request := UpgradeSystemFirmwareRequest{
+		Firmware: setAttachmentData{
+			ContentType: "application/octet-stream",
+			Include:     setXopInclude{Href: "cid:firmware@onvif"},
+		},
+	}
+	response := &UpgradeSystemFirmwareResponse{}
+	err = device.callMethodWithMTOM(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/UpgradeSystemFirmware", request, response,
+		[]mtomAttachment{{ContentID: "firmware@onvif", ContentType: "application/octet-stream", Data: data}})
+	if err != nil {
+		log.Println("UpgradeSystemFirmware fail", err)
+		return "", err
+	}
+
+	return response.Message, nil
+}
+
+// 完整的固件升级流程：上传固件，等待设备重启上线，并核对固件版本。
+// 设备以ActionNotSupported拒绝StartFirmwareUpgrade时改用UpgradeSystemFirmware，其他错误直接返回。
+// expectedVersion为空时不核对版本，timeout为上传完成后等待设备重启并重新上线的总时间
+func (device *OnvifDevice) UpgradeFirmware(path, expectedVersion string, progress UploadProgress, timeout time.Duration) (*DeviceInformation, error) {
+	if err := device.ensureCapabilities(); err != nil {
+		return nil, err
+	}
+	if !device.Capabilities.Capabilities.Device.FirmwareUpgrade {
+		return nil, errors.New("the device do not support firmware upgrade")
+	}
+
+	before, err := device.GetDeviceInformation()
+	if err != nil {
+		return nil, err
+	}
+
+	downTime := time.Duration(0)
+	info, err := device.StartFirmwareUpgrade()
+	if err == nil {
+		if err := device.UploadFirmware(info, path, progress); err != nil {
+			return nil, err
+		}
+		downTime = info.ExpectedDownTime
+	} else if isActionNotSupported(err) {
+		log.Println("StartFirmwareUpgrade not supported, fall back to UpgradeSystemFirmware")
+		if _, err := device.UpgradeSystemFirmware(path); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+
+	start := time.Now()
+	if downTime > 0 {
+		time.Sleep(downTime)
+	} else if !device.waitUntilDown(timeout / 2) {
+		log.Printf("device %s did not go down within %v, assuming it already rebooted", device.DeviceIp, timeout/2)
+	}
+
+	if err := device.WaitUntilReady(timeout - time.Since(start)); err != nil {
+		return nil, err
+	}
+
+	after, err := device.GetDeviceInformation()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("UpgradeFirmware: %s -> %s", before.FirmwareVersion, after.FirmwareVersion)
+	if expectedVersion != "" && after.FirmwareVersion != expectedVersion {
+		return after, fmt.Errorf("firmware version is %s after upgrade, expected %s",
+			after.FirmwareVersion, expectedVersion)
+	}
+
+	return after, nil
+}
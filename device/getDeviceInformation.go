@@ -0,0 +1,73 @@
+package device
+
+import (
+	"errors"
+	"log"
+	"strings"
+)
+
+type DeviceInformationRequest struct {
+	XMLName string `xml:"tds:GetDeviceInformation"`
+}
+
+type DeviceInformationResponse struct {
+	XMLName     string            `xml:"Envelope"`
+	Information DeviceInformation `xml:"Body>GetDeviceInformationResponse"`
+}
+
+type DeviceInformation struct {
+	Manufacturer    string `xml:"Manufacturer"`
+	Model           string `xml:"Model"`
+	FirmwareVersion string `xml:"FirmwareVersion"`
+	SerialNumber    string `xml:"SerialNumber"`
+	HardwareId      string `xml:"HardwareId"`
+}
+
+// 设备身份：设备信息与能力集的组合，以序列号作为唯一标识
+type DeviceIdentity struct {
+	DeviceInformation
+	DeviceIp     string
+	Capabilities Capabilities
+}
+
+// 资产库使用的主键，即去掉首尾空白的序列号
+func (identity *DeviceIdentity) Key() string {
+	return strings.TrimSpace(identity.SerialNumber)
+}
+
+// 获取厂商、型号、固件版本、序列号和硬件ID
+func (device *OnvifDevice) GetDeviceInformation() (*DeviceInformation, error) {
+	var request DeviceInformationRequest
+	response := &DeviceInformationResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetDeviceInformation", request, response)
+	if err != nil {
+		log.Println("GetDeviceInformation fail", err)
+		return nil, err
+	}
+
+	return &response.Information, nil
+}
+
+// 获取设备身份，序列号为空时仍返回已获取的信息并报错
+func (device *OnvifDevice) GetDeviceIdentity() (*DeviceIdentity, error) {
+	if err := device.ensureCapabilities(); err != nil {
+		return nil, err
+	}
+
+	info, err := device.GetDeviceInformation()
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &DeviceIdentity{
+		DeviceInformation: *info,
+		DeviceIp:          device.DeviceIp,
+		Capabilities:      device.Capabilities.Capabilities,
+	}
+	if identity.Key() == "" {
+		return identity, errors.New("device reported an empty serial number")
+	}
+
+	return identity, nil
+}
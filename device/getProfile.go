@@ -38,6 +38,7 @@ type Profile struct {
 	AudioEncoder   AudioEncoderConfiguration   `xml:"AudioEncoderConfiguration"`   //音频编码配置
 	VideoAnalytics VideoAnalyticsConfiguration `xml:"VideoAnalyticsConfiguration"` //视频分析配置
 	PTZ            PTZConfiguration            `xml:"PTZConfiguration"`            //云台配置
+	Metadata       MetadataConfiguration       `xml:"MetadataConfiguration"`       //元数据配置
 }
 
 /******************************************************************
@@ -80,6 +81,7 @@ type VideoEncoderConfiguration struct {
 	Resolution     Resolution  `xml:"Resolution"`
 	Quality        string      `xml:"Quality"`
 	RateControl    RateControl `xml:"RateControl"`
+	MPEG4          MPEG4       `xml:"MPEG4"`
 	H264           H264        `xml:"H264"`
 	Multicast      Multicast   `xml:"Multicast"`
 	SessionTimeout string      `xml:"SessionTimeout"`
@@ -96,21 +98,28 @@ type RateControl struct {
 	BitrateLimit     int `xml:"BitrateLimit"`
 }
 
+type MPEG4 struct {
+	GovLength    int    `xml:"GovLength"`
+	Mpeg4Profile string `xml:"Mpeg4Profile"`
+}
+
 type H264 struct {
 	GovLength   int    `xml:"GovLength"`
 	H264Profile string `xml:"H264Profile"`
 }
 
 type Multicast struct {
-	Address   string `xml:"Address"`
-	Port      int    `xml:"Port"`
-	TTL       int    `xml:"TTL"`
-	AutoStart bool   `xml:"AutoStart"`
+	Address   string  `xml:"-"`       //为兼容保留，设备返回的地址在IPAddress中；设置时IPAddress为空则使用此地址
+	IPAddress Address `xml:"Address"` //组播地址
+	Port      int     `xml:"Port"`
+	TTL       int     `xml:"TTL"`
+	AutoStart bool    `xml:"AutoStart"`
 }
 
 type Address struct {
 	Type        string `xml:"Type"`
 	IPv4Address string `xml:"IPv4Address"`
+	IPv6Address string `xml:"IPv6Address"`
 }
 
 /******************************************************************
@@ -1,19 +1,15 @@
 package device
 
 import (
-	"bytes"
 	"crypto/md5"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/hex"
-	"encoding/xml"
 	"errors"
-	"fmt"
-	"io"
-	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 )
@@ -26,8 +22,9 @@ type StreamUriRequest struct {
 }
 
 type StreamUriResponse struct {
-	XMLName  string   `xml:"Envelope"`
-	MediaUri MediaUri `xml:"Body>GetStreamUriResponse>MediaUri"`
+	XMLName  string    `xml:"Envelope"`
+	MediaUri MediaUri  `xml:"Body>GetStreamUriResponse>MediaUri"`
+	Fetched  time.Time `xml:"-"` //获取时间，用于判断Timeout
 }
 
 type MediaUri struct {
@@ -42,142 +39,131 @@ const (
 	userAgent = "AtScale"
 )
 
-func (device *OnvifDevice) getStreamUri() (*StreamUriResponse, error) {
+// 流类型
+const (
+	StreamTypeUnicast   = "RTP-Unicast"
+	StreamTypeMulticast = "RTP-Multicast"
+)
 
-	log.Println("enter getStreamUri")
-	log.Println("enter getStreamUri2")
-	if device.Profile == nil {
+// 传输协议，RTSP表示RTP over RTSP over TCP，HTTP表示RTP over RTSP over HTTP
+const (
+	TransportUDP  = "UDP"
+	TransportTCP  = "TCP"
+	TransportRTSP = "RTSP"
+	TransportHTTP = "HTTP"
+)
 
-		log.Println("device.Profile == nil")
-		_, err := device.GetProfiles()
-		if err != nil {
-			log.Println("device.GetProfiles fail")
-			return nil, errors.New("get profile fail")
-		}
-	}
+// 某个媒体文件的流地址，用于区分主码流、子码流和第三码流
+type ProfileStreamUri struct {
+	ProfileToken string
+	ProfileName  string
+	Encoding     string
+	Resolution   Resolution
+	MediaUri     MediaUri
+}
 
-	if device.Profile == nil {
-		log.Println("device.Profile == nil")
-		return nil, errors.New("device.Profile == nil")
+// 缓存的流地址是否仍然可用：InvalidAfterConnect的地址只能使用一次，
+// 有Timeout的地址超时后失效，InvalidAfterReboot的地址在设备重启后由invalidateCache清除
+func (streamUri *StreamUriResponse) valid() bool {
+	if streamUri.MediaUri.Uri == "" || streamUri.MediaUri.InvalidAfterConnected {
+		return false
 	}
 
-	if len(device.Profile.Profile) <= 0 {
-		log.Println("len(device.Profile.Profile) <= 0")
-		return nil, errors.New("len(device.Profile.Profile) <= 0")
+	timeout, err := parseDuration(streamUri.MediaUri.TimeOut)
+	if err != nil || timeout <= 0 {
+		return err == nil
 	}
+	return time.Since(streamUri.Fetched) < timeout
+}
 
-	token := device.Profile.Profile[0].Token
-
-	var profile StreamUriRequest
-	profile.ProfileToken = token
-	profile.Stream = "RTP-Unicast"
-	profile.Transport = "UDP"
-	element, err := buildElement(profile)
-	if err != nil {
-		log.Println("buildElement profile fail")
-		return nil, errors.New("buildElement profile fail")
+// 按媒体文件、流类型和传输协议获取流地址
+func (device *OnvifDevice) GetStreamUri(profileToken, streamType, protocol string) (*StreamUriResponse, error) {
+	if streamType != StreamTypeUnicast && streamType != StreamTypeMulticast {
+		return nil, errors.New("stream type must be RTP-Unicast or RTP-Multicast")
+	}
+	switch protocol {
+	case TransportUDP, TransportTCP, TransportRTSP, TransportHTTP:
+	default:
+		return nil, errors.New("transport protocol must be UDP, TCP, RTSP or HTTP")
 	}
 
-	soap := NewEmptySOAP()
-	soap.AddBodyContent(element)
-	//soap.AddWSSecurity(device.User, device.Passwd)
-	httpbody := bytes.NewBufferString(soap.String())
-
-	endpoint := device.Capabilities.Capabilities.Media.XAddr
-	client := &http.Client{}
-	req, err := http.NewRequest("POST", endpoint, httpbody)
+	mediaAddr, err := device.mediaServiceAddr()
 	if err != nil {
-		log.Println("http.NewRequest fail", err)
 		return nil, err
 	}
 
-	req.Header.Add("SOAPAction", "'http://www.onvif.org/ver10/media/wsdl/GetStreamUri'")
-	req.Header.Set("Content-Type", "application/soap+xml; charset=utf-8")
-
-	resp, err := client.Do(req)
+	request := StreamUriRequest{
+		ProfileToken: profileToken,
+		Stream:       streamType,
+		Transport:    protocol,
+	}
+	response := &StreamUriResponse{}
+	err = device.callMethod(mediaAddr, "http://www.onvif.org/ver10/media/wsdl/GetStreamUri", request, response)
 	if err != nil {
+		log.Println("GetStreamUri fail", err)
 		return nil, err
 	}
 
-	log.Println("getStreamUri: resp.StatusCode:", resp.StatusCode)
-
-	if resp.StatusCode == 401 {
-		var authorization map[string]string = DigestAuthParams(resp)
-		realmHeader := authorization["realm"]
-		qopHeader := authorization["qop"]
-		nonceHeader := authorization["nonce"]
-
-		// A1
-		h := md5.New()
-		A1 := fmt.Sprintf("%s:%s:%s", device.User, realmHeader, device.Passwd)
-		io.WriteString(h, A1)
-		HA1 := hex.EncodeToString(h.Sum(nil))
-
-		// A2
-		h = md5.New()
-		A2 := fmt.Sprintf("POST:%s", "/onvif/Media")
-		io.WriteString(h, A2)
-		HA2 := hex.EncodeToString(h.Sum(nil))
-
-		cnonce := getCnonce()
-		response := getMD5(fmt.Sprintf("%s:%s:%v:%s:%s:%s", HA1, nonceHeader, nc, cnonce, qopHeader, HA2))
-
-		AuthHeader := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s", algorithm=MD5, qop=%s, nc=00000001, cnonce="%s"`,
-			device.User, realmHeader, nonceHeader, "/onvif/Media", response, qopHeader, cnonce)
+	response.Fetched = time.Now()
+	return response, nil
+}
 
-		httpbody := bytes.NewBufferString(soap.String())
-		req, err = http.NewRequest("POST", endpoint, httpbody)
-		if err != nil {
-			log.Println("http.NewRequest fail", err)
-			return nil, err
-		}
+// 获取所有媒体文件的流地址，按分辨率从高到低排序，
+// 通常依次为主码流、子码流和第三码流
+func (device *OnvifDevice) GetStreamUris(streamType, protocol string) ([]ProfileStreamUri, error) {
+	if _, err := device.GetProfiles(); err != nil {
+		return nil, err
+	}
 
-		req.Header.Add("SOAPAction", "'http://www.onvif.org/ver10/media/wsdl/GetStreamUri'")
-		req.Header.Set("Content-Type", "application/soap+xml; charset=utf-8")
-		req.Header.Set("Authorization", AuthHeader)
-		resp, err = client.Do(req)
+	var result []ProfileStreamUri
+	for _, profile := range device.Profile.Profile {
+		streamUri, err := device.GetStreamUri(profile.Token, streamType, protocol)
 		if err != nil {
-			log.Println("getStreamUri: client.Do fail, err", err)
 			return nil, err
 		}
-		defer resp.Body.Close()
-
-		log.Println("getStreamUri: with auth, resp.StatusCode:", resp.StatusCode)
+		result = append(result, ProfileStreamUri{
+			ProfileToken: profile.Token,
+			ProfileName:  profile.Name,
+			Encoding:     profile.VideoEncoder.Encoding,
+			Resolution:   profile.VideoEncoder.Resolution,
+			MediaUri:     streamUri.MediaUri,
+		})
 	}
 
-	if resp.StatusCode != 200 {
-		return nil, errors.New("resp.StatusCode != 200")
-	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Resolution.Width*result[i].Resolution.Height >
+			result[j].Resolution.Width*result[j].Resolution.Height
+	})
 
-	s, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
+	return result, nil
+}
+
+func (device *OnvifDevice) getStreamUri() (*StreamUriResponse, error) {
+	if err := device.ensureProfiles(); err != nil {
+		log.Println("getStreamUri:", err)
 		return nil, err
 	}
 
-	ii := &StreamUriResponse{}
-
-	err = xml.Unmarshal(s, ii)
+	token := device.Profile.Profile[0].Token
+	streamUri, err := device.GetStreamUri(token, StreamTypeUnicast, TransportUDP)
 	if err != nil {
-		log.Println("xml.Unmarshal fail", err)
 		return nil, err
 	}
 
-	device.StreamUri = ii
+	device.StreamUri = streamUri
 
-	return ii, nil
+	return streamUri, nil
 }
 
+// 第一个媒体文件的单播流地址，缓存的地址失效后重新获取
 func (device *OnvifDevice) GetMediaUri() (string, error) {
-	if device.StreamUri == nil {
-		device.getStreamUri()
-	}
-
-	if device.StreamUri == nil {
-		return "", errors.New("get StreamUri fail")
+	if device.StreamUri == nil || !device.StreamUri.valid() {
+		if _, err := device.getStreamUri(); err != nil {
+			return "", err
+		}
 	}
 
 	return device.StreamUri.MediaUri.Uri, nil
-
 }
 
 func DigestAuthParams(r *http.Response) map[string]string {
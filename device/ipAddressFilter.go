@@ -0,0 +1,217 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// IP地址过滤类型
+const (
+	IPAddressFilterAllow = "Allow"
+	IPAddressFilterDeny  = "Deny"
+)
+
+// IP地址过滤规则，Allow表示只允许列表中的地址访问，Deny表示拒绝列表中的地址
+type IPAddressFilter struct {
+	Type        string              `xml:"Type"`
+	IPv4Address []PrefixedIPAddress `xml:"IPv4Address"`
+	IPv6Address []PrefixedIPAddress `xml:"IPv6Address"`
+}
+
+type setIPAddressFilter struct {
+	Type        string                 `xml:"tt:Type"`
+	IPv4Address []setPrefixedIPAddress `xml:"tt:IPv4Address"`
+	IPv6Address []setPrefixedIPAddress `xml:"tt:IPv6Address"`
+}
+
+// 由CIDR列表生成过滤规则，例如"10.1.0.0/16"、"fd00::/8"，不带前缀长度时视为单个地址
+func NewIPAddressFilter(filterType string, cidrs ...string) (*IPAddressFilter, error) {
+	if filterType != IPAddressFilterAllow && filterType != IPAddressFilterDeny {
+		return nil, errors.New("filter type must be Allow or Deny")
+	}
+
+	filter := &IPAddressFilter{Type: filterType}
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", cidr)
+			}
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+
+		ip, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		prefixLength, _ := network.Mask.Size()
+		if ip.To4() != nil {
+			filter.IPv4Address = append(filter.IPv4Address, PrefixedIPAddress{
+				Address:      network.IP.String(),
+				PrefixLength: prefixLength,
+			})
+		} else {
+			filter.IPv6Address = append(filter.IPv6Address, PrefixedIPAddress{
+				Address:      network.IP.String(),
+				PrefixLength: prefixLength,
+			})
+		}
+	}
+
+	return filter, nil
+}
+
+// 以CIDR形式列出过滤规则中的地址
+func (filter *IPAddressFilter) CIDRs() []string {
+	var result []string
+	for _, address := range filter.IPv4Address {
+		result = append(result, address.Address+"/"+strconv.Itoa(address.PrefixLength))
+	}
+	for _, address := range filter.IPv6Address {
+		result = append(result, address.Address+"/"+strconv.Itoa(address.PrefixLength))
+	}
+	return result
+}
+
+func (filter *IPAddressFilter) toSet() (setIPAddressFilter, error) {
+	if filter.Type != IPAddressFilterAllow && filter.Type != IPAddressFilterDeny {
+		return setIPAddressFilter{}, errors.New("filter type must be Allow or Deny")
+	}
+
+	return setIPAddressFilter{
+		Type:        filter.Type,
+		IPv4Address: toSetPrefixedIPAddresses(filter.IPv4Address),
+		IPv6Address: toSetPrefixedIPAddresses(filter.IPv6Address),
+	}, nil
+}
+
+type IPAddressFilterRequest struct {
+	XMLName string `xml:"tds:GetIPAddressFilter"`
+}
+
+type IPAddressFilterResponse struct {
+	XMLName         string          `xml:"Envelope"`
+	IPAddressFilter IPAddressFilter `xml:"Body>GetIPAddressFilterResponse>IPAddressFilter"`
+}
+
+type SetIPAddressFilterRequest struct {
+	XMLName         string             `xml:"tds:SetIPAddressFilter"`
+	IPAddressFilter setIPAddressFilter `xml:"tds:IPAddressFilter"`
+}
+
+type AddIPAddressFilterRequest struct {
+	XMLName         string             `xml:"tds:AddIPAddressFilter"`
+	IPAddressFilter setIPAddressFilter `xml:"tds:IPAddressFilter"`
+}
+
+type RemoveIPAddressFilterRequest struct {
+	XMLName         string             `xml:"tds:RemoveIPAddressFilter"`
+	IPAddressFilter setIPAddressFilter `xml:"tds:IPAddressFilter"`
+}
+
+type IPAddressFilterEmptyResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+func (device *OnvifDevice) checkIPFilter() error {
+	if err := device.ensureCapabilities(); err != nil {
+		return err
+	}
+	if !device.Capabilities.Capabilities.Device.IPFilter {
+		return errors.New("the device do not support ip address filter")
+	}
+	return nil
+}
+
+// 获取IP地址过滤规则
+func (device *OnvifDevice) GetIPAddressFilter() (*IPAddressFilter, error) {
+	if err := device.checkIPFilter(); err != nil {
+		return nil, err
+	}
+
+	var request IPAddressFilterRequest
+	response := &IPAddressFilterResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetIPAddressFilter", request, response)
+	if err != nil {
+		log.Println("GetIPAddressFilter fail", err)
+		return nil, err
+	}
+
+	return &response.IPAddressFilter, nil
+}
+
+// 替换整个IP地址过滤规则
+func (device *OnvifDevice) SetIPAddressFilter(filter *IPAddressFilter) error {
+	if err := device.checkIPFilter(); err != nil {
+		return err
+	}
+
+	setFilter, err := filter.toSet()
+	if err != nil {
+		return err
+	}
+
+	request := SetIPAddressFilterRequest{IPAddressFilter: setFilter}
+	err = device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/SetIPAddressFilter", request, &IPAddressFilterEmptyResponse{})
+	if err != nil {
+		log.Println("SetIPAddressFilter fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 向现有规则中添加地址
+func (device *OnvifDevice) AddIPAddressFilter(filter *IPAddressFilter) error {
+	if err := device.checkIPFilter(); err != nil {
+		return err
+	}
+
+	setFilter, err := filter.toSet()
+	if err != nil {
+		return err
+	}
+
+	request := AddIPAddressFilterRequest{IPAddressFilter: setFilter}
+	err = device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/AddIPAddressFilter", request, &IPAddressFilterEmptyResponse{})
+	if err != nil {
+		log.Println("AddIPAddressFilter fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 从现有规则中删除地址
+func (device *OnvifDevice) RemoveIPAddressFilter(filter *IPAddressFilter) error {
+	if err := device.checkIPFilter(); err != nil {
+		return err
+	}
+
+	setFilter, err := filter.toSet()
+	if err != nil {
+		return err
+	}
+
+	request := RemoveIPAddressFilterRequest{IPAddressFilter: setFilter}
+	err = device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/RemoveIPAddressFilter", request, &IPAddressFilterEmptyResponse{})
+	if err != nil {
+		log.Println("RemoveIPAddressFilter fail", err)
+		return err
+	}
+
+	return nil
+}
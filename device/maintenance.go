@@ -0,0 +1,132 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// 恢复出厂设置方式，Soft保留网络等基本设置，Hard恢复全部设置
+const (
+	FactoryDefaultSoft = "Soft"
+	FactoryDefaultHard = "Hard"
+)
+
+const pollInterval = 2 * time.Second
+
+type SystemRebootRequest struct {
+	XMLName string `xml:"tds:SystemReboot"`
+}
+
+type SystemRebootResponse struct {
+	XMLName string `xml:"Envelope"`
+	Message string `xml:"Body>SystemRebootResponse>Message"`
+}
+
+type SetSystemFactoryDefaultRequest struct {
+	XMLName        string `xml:"tds:SetSystemFactoryDefault"`
+	FactoryDefault string `xml:"tds:FactoryDefault"`
+}
+
+type SetSystemFactoryDefaultResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+// 重启设备，返回设备给出的提示信息
+func (device *OnvifDevice) SystemReboot() (string, error) {
+	var request SystemRebootRequest
+	response := &SystemRebootResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/SystemReboot", request, response)
+	if err != nil {
+		log.Println("SystemReboot fail", err)
+		return "", err
+	}
+
+	log.Println("SystemReboot:", response.Message)
+	return response.Message, nil
+}
+
+// 恢复出厂设置，factoryDefault取值Soft或Hard
+func (device *OnvifDevice) SetSystemFactoryDefault(factoryDefault string) error {
+	if factoryDefault != FactoryDefaultSoft && factoryDefault != FactoryDefaultHard {
+		return errors.New("factory default must be Soft or Hard")
+	}
+
+	request := SetSystemFactoryDefaultRequest{FactoryDefault: factoryDefault}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/SetSystemFactoryDefault", request, &SetSystemFactoryDefaultResponse{})
+	if err != nil {
+		log.Println("SetSystemFactoryDefault fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 设备是否在线：正常应答、SOAP错误和401认证失败认为在线。
+// 恢复出厂设置后账号被重置，设备会以NotAuthorized应答；
+// 启动过程中的Web服务器可能返回503、404等，此时ONVIF服务还未就绪，与网络错误一样认为不在线
+func (device *OnvifDevice) responding() (bool, error) {
+	_, err := device.GetSystemDateAndTime()
+	if err == nil {
+		return true, nil
+	}
+
+	switch e := err.(type) {
+	case *soapFault:
+		return true, err
+	case *httpStatusError:
+		return e.StatusCode == http.StatusUnauthorized, err
+	}
+	return false, err
+}
+
+// 轮询GetSystemDateAndTime直到设备应答，之后清空缓存的能力集和媒体文件
+func (device *OnvifDevice) WaitUntilReady(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		up, err := device.responding()
+		if up {
+			device.invalidateCache()
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("device %s not ready after %v: %v", device.DeviceIp, timeout, err)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// 等待设备停止应答，重启命令发出后设备通常还会继续应答一段时间。
+// 设备可能在两次轮询之间完成重启，超时未观察到下线时返回false
+func (device *OnvifDevice) waitUntilDown(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if up, _ := device.responding(); !up {
+			return true
+		}
+
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// 重启设备并等待其重新上线，timeout为整个过程的超时时间，其中最多一半用于等待设备下线
+func (device *OnvifDevice) RebootAndWait(timeout time.Duration) error {
+	start := time.Now()
+	if _, err := device.SystemReboot(); err != nil {
+		return err
+	}
+
+	if !device.waitUntilDown(timeout / 2) {
+		log.Printf("device %s did not go down within %v, assuming it already rebooted", device.DeviceIp, timeout/2)
+	}
+
+	return device.WaitUntilReady(timeout - time.Since(start))
+}
@@ -0,0 +1,275 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 元数据配置，决定元数据流中是否包含云台状态、事件和视频分析结果。Events为nil时不输出事件
+type MetadataConfiguration struct {
+	Token          string             `xml:"token,attr"`
+	Name           string             `xml:"Name"`
+	UseCount       int                `xml:"UseCount"`
+	PTZStatus      PTZFilter          `xml:"PTZStatus"`
+	Events         *EventSubscription `xml:"Events"`
+	Analytics      bool               `xml:"Analytics"`
+	Multicast      Multicast          `xml:"Multicast"`
+	SessionTimeout string             `xml:"SessionTimeout"`
+}
+
+// 元数据中包含的云台信息
+type PTZFilter struct {
+	Status   bool `xml:"Status"`
+	Position bool `xml:"Position"`
+}
+
+// 元数据中包含的事件，Filter为nil时包含所有事件
+type EventSubscription struct {
+	Filter *EventFilter `xml:"Filter"`
+}
+
+// 事件过滤条件，主题表达式中的tns1前缀表示ONVIF主题命名空间
+type EventFilter struct {
+	TopicExpression []FilterExpression `xml:"TopicExpression"`
+	MessageContent  []FilterExpression `xml:"MessageContent"`
+}
+
+type FilterExpression struct {
+	Dialect    string `xml:"Dialect,attr"`
+	Expression string `xml:",chardata"`
+}
+
+// 元数据配置可选参数
+type MetadataConfigurationOptions struct {
+	PTZStatusFilterOptions PTZStatusFilterOptions `xml:"PTZStatusFilterOptions"`
+	CompressionTypes       []string               `xml:"Extension>CompressionType"`
+}
+
+// 设备能否在元数据中输出云台状态和位置
+type PTZStatusFilterOptions struct {
+	PanTiltStatusSupported   bool `xml:"PanTiltStatusSupported"`
+	ZoomStatusSupported      bool `xml:"ZoomStatusSupported"`
+	PanTiltPositionSupported bool `xml:"PanTiltPositionSupported"`
+	ZoomPositionSupported    bool `xml:"ZoomPositionSupported"`
+}
+
+// 检查元数据配置中请求的云台信息设备是否支持
+func (options *MetadataConfigurationOptions) Validate(configuration MetadataConfiguration) error {
+	ptz := options.PTZStatusFilterOptions
+	if configuration.PTZStatus.Status && !ptz.PanTiltStatusSupported && !ptz.ZoomStatusSupported {
+		return errors.New("invalid metadata configuration: ptz status not supported")
+	}
+	if configuration.PTZStatus.Position && !ptz.PanTiltPositionSupported && !ptz.ZoomPositionSupported {
+		return errors.New("invalid metadata configuration: ptz position not supported")
+	}
+	return nil
+}
+
+type setMetadataConfiguration struct {
+	Token          string                `xml:"token,attr"`
+	Name           string                `xml:"tt:Name"`
+	UseCount       int                   `xml:"tt:UseCount"`
+	PTZStatus      setPTZFilter          `xml:"tt:PTZStatus"`
+	Events         *setEventSubscription `xml:"tt:Events,omitempty"`
+	Analytics      bool                  `xml:"tt:Analytics"`
+	Multicast      setMulticast          `xml:"tt:Multicast"`
+	SessionTimeout string                `xml:"tt:SessionTimeout,omitempty"`
+}
+
+type setPTZFilter struct {
+	Status   bool `xml:"tt:Status"`
+	Position bool `xml:"tt:Position"`
+}
+
+type setEventSubscription struct {
+	Filter *setEventFilter `xml:"tt:Filter,omitempty"`
+}
+
+type setEventFilter struct {
+	TopicExpression []setFilterExpression `xml:"wsnt:TopicExpression"`
+	MessageContent  []setFilterExpression `xml:"wsnt:MessageContent"`
+}
+
+type setFilterExpression struct {
+	Dialect    string `xml:"Dialect,attr"`
+	Expression string `xml:",chardata"`
+}
+
+func toSetFilterExpressions(expressions []FilterExpression) []setFilterExpression {
+	var result []setFilterExpression
+	for _, expression := range expressions {
+		result = append(result, setFilterExpression{Dialect: expression.Dialect, Expression: expression.Expression})
+	}
+	return result
+}
+
+func (configuration *MetadataConfiguration) toSet() setMetadataConfiguration {
+	result := setMetadataConfiguration{
+		Token:          configuration.Token,
+		Name:           configuration.Name,
+		UseCount:       configuration.UseCount,
+		PTZStatus:      setPTZFilter{Status: configuration.PTZStatus.Status, Position: configuration.PTZStatus.Position},
+		Analytics:      configuration.Analytics,
+		Multicast:      configuration.Multicast.toSet(),
+		SessionTimeout: configuration.SessionTimeout,
+	}
+	if configuration.Events != nil {
+		result.Events = &setEventSubscription{}
+		if filter := configuration.Events.Filter; filter != nil {
+			result.Events.Filter = &setEventFilter{
+				TopicExpression: toSetFilterExpressions(filter.TopicExpression),
+				MessageContent:  toSetFilterExpressions(filter.MessageContent),
+			}
+		}
+	}
+	return result
+}
+
+type MetadataConfigurationsRequest struct {
+	XMLName string `xml:"trt:GetMetadataConfigurations"`
+}
+
+type MetadataConfigurationsResponse struct {
+	XMLName        string                  `xml:"Envelope"`
+	Configurations []MetadataConfiguration `xml:"Body>GetMetadataConfigurationsResponse>Configurations"`
+}
+
+type MetadataConfigurationOptionsRequest struct {
+	XMLName            string `xml:"trt:GetMetadataConfigurationOptions"`
+	ConfigurationToken string `xml:"trt:ConfigurationToken,omitempty"`
+	ProfileToken       string `xml:"trt:ProfileToken,omitempty"`
+}
+
+type MetadataConfigurationOptionsResponse struct {
+	XMLName string                       `xml:"Envelope"`
+	Options MetadataConfigurationOptions `xml:"Body>GetMetadataConfigurationOptionsResponse>Options"`
+}
+
+type MetadataConfigurationRequest struct {
+	XMLName            string `xml:"trt:GetMetadataConfiguration"`
+	ConfigurationToken string `xml:"trt:ConfigurationToken"`
+}
+
+type MetadataConfigurationResponse struct {
+	XMLName       string                `xml:"Envelope"`
+	Configuration MetadataConfiguration `xml:"Body>GetMetadataConfigurationResponse>Configuration"`
+}
+
+type SetMetadataConfigurationRequest struct {
+	XMLName          string                   `xml:"trt:SetMetadataConfiguration"`
+	Configuration    setMetadataConfiguration `xml:"trt:Configuration"`
+	ForcePersistence bool                     `xml:"trt:ForcePersistence"`
+}
+
+type MetadataEmptyResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+// 获取所有元数据配置
+func (device *OnvifDevice) GetMetadataConfigurations() ([]MetadataConfiguration, error) {
+	var request MetadataConfigurationsRequest
+	response := &MetadataConfigurationsResponse{}
+	if err := device.callMediaMethod("GetMetadataConfigurations", request, response); err != nil {
+		return nil, err
+	}
+	return response.Configurations, nil
+}
+
+// 获取元数据配置的可选参数，configurationToken、profileToken为空时不指定
+func (device *OnvifDevice) GetMetadataConfigurationOptions(configurationToken, profileToken string) (*MetadataConfigurationOptions, error) {
+	request := MetadataConfigurationOptionsRequest{
+		ConfigurationToken: configurationToken,
+		ProfileToken:       profileToken,
+	}
+	response := &MetadataConfigurationOptionsResponse{}
+	if err := device.callMediaMethod("GetMetadataConfigurationOptions", request, response); err != nil {
+		return nil, err
+	}
+	return &response.Options, nil
+}
+
+// 获取指定的元数据配置
+func (device *OnvifDevice) GetMetadataConfiguration(configurationToken string) (*MetadataConfiguration, error) {
+	if configurationToken == "" {
+		return nil, errors.New("metadata configuration token is empty")
+	}
+
+	request := MetadataConfigurationRequest{ConfigurationToken: configurationToken}
+	response := &MetadataConfigurationResponse{}
+	if err := device.callMediaMethod("GetMetadataConfiguration", request, response); err != nil {
+		return nil, err
+	}
+	return &response.Configuration, nil
+}
+
+// 修改元数据配置(云台状态、事件过滤、视频分析、组播)，发送前按设备声明的可选参数检查。
+// forcePersistence为false时设备重启后可能恢复原配置
+func (device *OnvifDevice) SetMetadataConfiguration(configuration MetadataConfiguration, forcePersistence bool) error {
+	if configuration.Token == "" {
+		return errors.New("metadata configuration token is empty")
+	}
+
+	options, err := device.GetMetadataConfigurationOptions(configuration.Token, "")
+	if err != nil {
+		return err
+	}
+	if err := options.Validate(configuration); err != nil {
+		return err
+	}
+
+	request := SetMetadataConfigurationRequest{
+		Configuration:    configuration.toSet(),
+		ForcePersistence: forcePersistence,
+	}
+	if err := device.callMediaMethod("SetMetadataConfiguration", request, &MetadataEmptyResponse{}); err != nil {
+		return err
+	}
+
+	// 缓存的媒体文件中包含旧配置
+	device.Profile = nil
+	return nil
+}
+
+// 为媒体文件开启元数据流：媒体文件没有元数据配置时添加一个兼容的配置(优先使用未被其他媒体文件使用的)，
+// 然后设置是否输出视频分析结果和事件。events为nil时不输出事件，events.Filter为nil时输出所有事件。
+// 元数据配置被多个媒体文件共用时，修改会同时影响这些媒体文件
+func (device *OnvifDevice) EnableProfileMetadata(profileToken string, analytics bool, events *EventSubscription) (*MetadataConfiguration, error) {
+	profile, err := device.findProfile(profileToken)
+	if err != nil {
+		return nil, err
+	}
+
+	configurationToken := profile.Metadata.Token
+	if configurationToken == "" {
+		compatible, err := device.GetCompatibleMetadataConfigurations(profileToken)
+		if err != nil {
+			return nil, err
+		}
+		if len(compatible) == 0 {
+			return nil, fmt.Errorf("no compatible metadata configuration for profile %s", profileToken)
+		}
+
+		configurationToken = compatible[0].Token
+		for _, configuration := range compatible {
+			if configuration.UseCount == 0 {
+				configurationToken = configuration.Token
+				break
+			}
+		}
+		if err := device.AddMetadataConfiguration(profileToken, configurationToken); err != nil {
+			return nil, err
+		}
+	}
+
+	configuration, err := device.GetMetadataConfiguration(configurationToken)
+	if err != nil {
+		return nil, err
+	}
+	configuration.Analytics = analytics
+	configuration.Events = events
+
+	if err := device.SetMetadataConfiguration(*configuration, true); err != nil {
+		return nil, err
+	}
+	return configuration, nil
+}
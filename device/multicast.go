@@ -0,0 +1,111 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// 生成组播配置，address必须是组播地址，例如"239.0.0.10"、"ff15::10"
+func NewMulticast(address string, port, ttl int, autoStart bool) (Multicast, error) {
+	ip := net.ParseIP(address)
+	if ip == nil || !ip.IsMulticast() {
+		return Multicast{}, fmt.Errorf("%q is not a multicast address", address)
+	}
+	if port <= 0 || port > 65535 {
+		return Multicast{}, fmt.Errorf("invalid multicast port %d", port)
+	}
+	if ttl <= 0 || ttl > 255 {
+		return Multicast{}, fmt.Errorf("invalid multicast ttl %d", ttl)
+	}
+
+	multicast := Multicast{Address: ip.String(), Port: port, TTL: ttl, AutoStart: autoStart}
+	if ip.To4() != nil {
+		multicast.IPAddress = Address{Type: NetworkHostIPv4, IPv4Address: ip.String()}
+	} else {
+		multicast.IPAddress = Address{Type: NetworkHostIPv6, IPv6Address: ip.String()}
+	}
+	return multicast, nil
+}
+
+type StartMulticastStreamingRequest struct {
+	XMLName      string `xml:"trt:StartMulticastStreaming"`
+	ProfileToken string `xml:"trt:ProfileToken"`
+}
+
+type StopMulticastStreamingRequest struct {
+	XMLName      string `xml:"trt:StopMulticastStreaming"`
+	ProfileToken string `xml:"trt:ProfileToken"`
+}
+
+type MulticastEmptyResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+// 修改视频编码配置的组播地址、端口、TTL和自动启动
+func (device *OnvifDevice) SetVideoEncoderMulticast(configurationToken string, multicast Multicast) error {
+	configurations, err := device.GetVideoEncoderConfigurations()
+	if err != nil {
+		return err
+	}
+
+	for _, configuration := range configurations {
+		if configuration.Token == configurationToken {
+			configuration.Multicast = multicast
+			return device.SetVideoEncoderConfiguration(configuration, true)
+		}
+	}
+	return fmt.Errorf("video encoder configuration %s not found", configurationToken)
+}
+
+// 修改音频编码配置的组播设置
+func (device *OnvifDevice) SetAudioEncoderMulticast(configurationToken string, multicast Multicast) error {
+	configurations, err := device.GetAudioEncoderConfigurations()
+	if err != nil {
+		return err
+	}
+
+	for _, configuration := range configurations {
+		if configuration.Token == configurationToken {
+			configuration.Multicast = multicast
+			return device.SetAudioEncoderConfiguration(configuration, true)
+		}
+	}
+	return fmt.Errorf("audio encoder configuration %s not found", configurationToken)
+}
+
+// 修改元数据配置的组播设置
+func (device *OnvifDevice) SetMetadataMulticast(configurationToken string, multicast Multicast) error {
+	configuration, err := device.GetMetadataConfiguration(configurationToken)
+	if err != nil {
+		return err
+	}
+
+	configuration.Multicast = multicast
+	return device.SetMetadataConfiguration(*configuration, true)
+}
+
+// 按媒体文件中各配置的组播设置开始组播
+func (device *OnvifDevice) StartMulticastStreaming(profileToken string) error {
+	if profileToken == "" {
+		return errors.New("profile token is empty")
+	}
+
+	request := StartMulticastStreamingRequest{ProfileToken: profileToken}
+	return device.callMediaMethod("StartMulticastStreaming", request, &MulticastEmptyResponse{})
+}
+
+// 停止媒体文件的组播
+func (device *OnvifDevice) StopMulticastStreaming(profileToken string) error {
+	if profileToken == "" {
+		return errors.New("profile token is empty")
+	}
+
+	request := StopMulticastStreamingRequest{ProfileToken: profileToken}
+	return device.callMediaMethod("StopMulticastStreaming", request, &MulticastEmptyResponse{})
+}
+
+// 获取媒体文件的组播流地址，客户端通过RTSP发起组播会话
+func (device *OnvifDevice) GetMulticastStreamUri(profileToken string) (*StreamUriResponse, error) {
+	return device.GetStreamUri(profileToken, StreamTypeMulticast, TransportUDP)
+}
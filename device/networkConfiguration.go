@@ -0,0 +1,304 @@
+package device
+
+import (
+	"errors"
+	"log"
+)
+
+// 网络协议名称
+const (
+	NetworkProtocolHTTP  = "HTTP"
+	NetworkProtocolHTTPS = "HTTPS"
+	NetworkProtocolRTSP  = "RTSP"
+)
+
+// IP地址，Type取值IPv4或IPv6
+type IPAddress struct {
+	Type        string `xml:"Type"`
+	IPv4Address string `xml:"IPv4Address"`
+	IPv6Address string `xml:"IPv6Address"`
+}
+
+type setIPAddress struct {
+	Type        string `xml:"tt:Type"`
+	IPv4Address string `xml:"tt:IPv4Address,omitempty"`
+	IPv6Address string `xml:"tt:IPv6Address,omitempty"`
+}
+
+func toSetIPAddresses(addresses []IPAddress) []setIPAddress {
+	var result []setIPAddress
+	for _, address := range addresses {
+		result = append(result, setIPAddress{
+			Type:        address.Type,
+			IPv4Address: address.IPv4Address,
+			IPv6Address: address.IPv6Address,
+		})
+	}
+	return result
+}
+
+// DNS
+type DNSRequest struct {
+	XMLName string `xml:"tds:GetDNS"`
+}
+
+type DNSResponse struct {
+	XMLName        string         `xml:"Envelope"`
+	DNSInformation DNSInformation `xml:"Body>GetDNSResponse>DNSInformation"`
+}
+
+type DNSInformation struct {
+	FromDHCP     bool        `xml:"FromDHCP"`
+	SearchDomain []string    `xml:"SearchDomain"`
+	DNSFromDHCP  []IPAddress `xml:"DNSFromDHCP"`
+	DNSManual    []IPAddress `xml:"DNSManual"`
+}
+
+type SetDNSRequest struct {
+	XMLName      string         `xml:"tds:SetDNS"`
+	FromDHCP     bool           `xml:"tds:FromDHCP"`
+	SearchDomain []string       `xml:"tds:SearchDomain"`
+	DNSManual    []setIPAddress `xml:"tds:DNSManual"`
+}
+
+type SetDNSResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+// 主机名
+type HostnameRequest struct {
+	XMLName string `xml:"tds:GetHostname"`
+}
+
+type HostnameResponse struct {
+	XMLName             string              `xml:"Envelope"`
+	HostnameInformation HostnameInformation `xml:"Body>GetHostnameResponse>HostnameInformation"`
+}
+
+type HostnameInformation struct {
+	FromDHCP bool   `xml:"FromDHCP"`
+	Name     string `xml:"Name"`
+}
+
+type SetHostnameRequest struct {
+	XMLName string `xml:"tds:SetHostname"`
+	Name    string `xml:"tds:Name"`
+}
+
+type SetHostnameResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+type SetHostnameFromDHCPRequest struct {
+	XMLName  string `xml:"tds:SetHostnameFromDHCP"`
+	FromDHCP bool   `xml:"tds:FromDHCP"`
+}
+
+type SetHostnameFromDHCPResponse struct {
+	XMLName      string `xml:"Envelope"`
+	RebootNeeded bool   `xml:"Body>SetHostnameFromDHCPResponse>RebootNeeded"`
+}
+
+// 默认网关
+type NetworkDefaultGatewayRequest struct {
+	XMLName string `xml:"tds:GetNetworkDefaultGateway"`
+}
+
+type NetworkDefaultGatewayResponse struct {
+	XMLName        string         `xml:"Envelope"`
+	NetworkGateway NetworkGateway `xml:"Body>GetNetworkDefaultGatewayResponse>NetworkGateway"`
+}
+
+type NetworkGateway struct {
+	IPv4Address []string `xml:"IPv4Address"`
+	IPv6Address []string `xml:"IPv6Address"`
+}
+
+type SetNetworkDefaultGatewayRequest struct {
+	XMLName     string   `xml:"tds:SetNetworkDefaultGateway"`
+	IPv4Address []string `xml:"tds:IPv4Address"`
+	IPv6Address []string `xml:"tds:IPv6Address"`
+}
+
+type SetNetworkDefaultGatewayResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+// 网络协议(HTTP/HTTPS/RTSP)的开关和端口
+type NetworkProtocolsRequest struct {
+	XMLName string `xml:"tds:GetNetworkProtocols"`
+}
+
+type NetworkProtocolsResponse struct {
+	XMLName          string            `xml:"Envelope"`
+	NetworkProtocols []NetworkProtocol `xml:"Body>GetNetworkProtocolsResponse>NetworkProtocols"`
+}
+
+type NetworkProtocol struct {
+	Name    string `xml:"Name"`
+	Enabled bool   `xml:"Enabled"`
+	Port    []int  `xml:"Port"`
+}
+
+type SetNetworkProtocolsRequest struct {
+	XMLName          string               `xml:"tds:SetNetworkProtocols"`
+	NetworkProtocols []setNetworkProtocol `xml:"tds:NetworkProtocols"`
+}
+
+type setNetworkProtocol struct {
+	Name    string `xml:"tt:Name"`
+	Enabled bool   `xml:"tt:Enabled"`
+	Port    []int  `xml:"tt:Port"`
+}
+
+type SetNetworkProtocolsResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+// 获取DNS设置
+func (device *OnvifDevice) GetDNS() (*DNSInformation, error) {
+	var request DNSRequest
+	response := &DNSResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetDNS", request, response)
+	if err != nil {
+		log.Println("GetDNS fail", err)
+		return nil, err
+	}
+
+	return &response.DNSInformation, nil
+}
+
+// 设置DNS，fromDHCP为true时servers被忽略
+func (device *OnvifDevice) SetDNS(fromDHCP bool, searchDomain []string, servers []IPAddress) error {
+	request := SetDNSRequest{
+		FromDHCP:     fromDHCP,
+		SearchDomain: searchDomain,
+	}
+	if !fromDHCP {
+		request.DNSManual = toSetIPAddresses(servers)
+	}
+
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/SetDNS", request, &SetDNSResponse{})
+	if err != nil {
+		log.Println("SetDNS fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 获取主机名
+func (device *OnvifDevice) GetHostname() (*HostnameInformation, error) {
+	var request HostnameRequest
+	response := &HostnameResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetHostname", request, response)
+	if err != nil {
+		log.Println("GetHostname fail", err)
+		return nil, err
+	}
+
+	return &response.HostnameInformation, nil
+}
+
+// 设置主机名
+func (device *OnvifDevice) SetHostname(name string) error {
+	if name == "" {
+		return errors.New("hostname is empty")
+	}
+
+	request := SetHostnameRequest{Name: name}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/SetHostname", request, &SetHostnameResponse{})
+	if err != nil {
+		log.Println("SetHostname fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 设置主机名是否通过DHCP获取，返回值表示设备是否需要重启才能生效
+func (device *OnvifDevice) SetHostnameFromDHCP(fromDHCP bool) (bool, error) {
+	request := SetHostnameFromDHCPRequest{FromDHCP: fromDHCP}
+	response := &SetHostnameFromDHCPResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/SetHostnameFromDHCP", request, response)
+	if err != nil {
+		log.Println("SetHostnameFromDHCP fail", err)
+		return false, err
+	}
+
+	return response.RebootNeeded, nil
+}
+
+// 获取默认网关
+func (device *OnvifDevice) GetNetworkDefaultGateway() (*NetworkGateway, error) {
+	var request NetworkDefaultGatewayRequest
+	response := &NetworkDefaultGatewayResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetNetworkDefaultGateway", request, response)
+	if err != nil {
+		log.Println("GetNetworkDefaultGateway fail", err)
+		return nil, err
+	}
+
+	return &response.NetworkGateway, nil
+}
+
+// 设置默认网关
+func (device *OnvifDevice) SetNetworkDefaultGateway(gateway NetworkGateway) error {
+	request := SetNetworkDefaultGatewayRequest{
+		IPv4Address: gateway.IPv4Address,
+		IPv6Address: gateway.IPv6Address,
+	}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/SetNetworkDefaultGateway", request, &SetNetworkDefaultGatewayResponse{})
+	if err != nil {
+		log.Println("SetNetworkDefaultGateway fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 获取HTTP/HTTPS/RTSP等协议的开关和端口
+func (device *OnvifDevice) GetNetworkProtocols() ([]NetworkProtocol, error) {
+	var request NetworkProtocolsRequest
+	response := &NetworkProtocolsResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetNetworkProtocols", request, response)
+	if err != nil {
+		log.Println("GetNetworkProtocols fail", err)
+		return nil, err
+	}
+
+	return response.NetworkProtocols, nil
+}
+
+// 设置协议开关和端口，未列出的协议保持不变
+func (device *OnvifDevice) SetNetworkProtocols(protocols []NetworkProtocol) error {
+	if len(protocols) == 0 {
+		return errors.New("no network protocol to set")
+	}
+
+	var request SetNetworkProtocolsRequest
+	for _, protocol := range protocols {
+		request.NetworkProtocols = append(request.NetworkProtocols, setNetworkProtocol{
+			Name:    protocol.Name,
+			Enabled: protocol.Enabled,
+			Port:    protocol.Port,
+		})
+	}
+
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/SetNetworkProtocols", request, &SetNetworkProtocolsResponse{})
+	if err != nil {
+		log.Println("SetNetworkProtocols fail", err)
+		return err
+	}
+
+	return nil
+}
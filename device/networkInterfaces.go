@@ -0,0 +1,306 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+)
+
+// IPv6 DHCP方式
+const (
+	IPv6DHCPAuto      = "Auto"
+	IPv6DHCPStateful  = "Stateful"
+	IPv6DHCPStateless = "Stateless"
+	IPv6DHCPOff       = "Off"
+)
+
+type NetworkInterfacesRequest struct {
+	XMLName string `xml:"tds:GetNetworkInterfaces"`
+}
+
+type NetworkInterfacesResponse struct {
+	XMLName           string             `xml:"Envelope"`
+	NetworkInterfaces []NetworkInterface `xml:"Body>GetNetworkInterfacesResponse>NetworkInterfaces"`
+}
+
+type NetworkInterface struct {
+	Token   string               `xml:"token,attr"`
+	Enabled bool                 `xml:"Enabled"`
+	Info    NetworkInterfaceInfo `xml:"Info"`
+	Link    NetworkInterfaceLink `xml:"Link"`
+	IPv4    IPv4NetworkInterface `xml:"IPv4"`
+	IPv6    IPv6NetworkInterface `xml:"IPv6"`
+}
+
+type NetworkInterfaceInfo struct {
+	Name      string `xml:"Name"`
+	HwAddress string `xml:"HwAddress"`
+	MTU       int    `xml:"MTU"`
+}
+
+type NetworkInterfaceLink struct {
+	AdminSettings NetworkInterfaceConnectionSetting `xml:"AdminSettings"`
+	OperSettings  NetworkInterfaceConnectionSetting `xml:"OperSettings"`
+	InterfaceType int                               `xml:"InterfaceType"`
+}
+
+// 链路设置，Duplex取值Full或Half
+type NetworkInterfaceConnectionSetting struct {
+	AutoNegotiation bool   `xml:"AutoNegotiation"`
+	Speed           int    `xml:"Speed"`
+	Duplex          string `xml:"Duplex"`
+}
+
+type PrefixedIPAddress struct {
+	Address      string `xml:"Address"`
+	PrefixLength int    `xml:"PrefixLength"`
+}
+
+type IPv4NetworkInterface struct {
+	Enabled   bool                `xml:"Enabled"`
+	Manual    []PrefixedIPAddress `xml:"Config>Manual"`
+	LinkLocal *PrefixedIPAddress  `xml:"Config>LinkLocal"`
+	FromDHCP  *PrefixedIPAddress  `xml:"Config>FromDHCP"`
+	DHCP      bool                `xml:"Config>DHCP"`
+}
+
+type IPv6NetworkInterface struct {
+	Enabled            bool                `xml:"Enabled"`
+	AcceptRouterAdvert bool                `xml:"Config>AcceptRouterAdvert"`
+	DHCP               string              `xml:"Config>DHCP"`
+	Manual             []PrefixedIPAddress `xml:"Config>Manual"`
+	LinkLocal          []PrefixedIPAddress `xml:"Config>LinkLocal"`
+	FromDHCP           []PrefixedIPAddress `xml:"Config>FromDHCP"`
+	FromRA             []PrefixedIPAddress `xml:"Config>FromRA"`
+}
+
+// 网卡设置，nil字段表示保持设备当前设置不变
+type NetworkInterfaceSetConfiguration struct {
+	Enabled *bool
+	Link    *NetworkInterfaceConnectionSetting
+	MTU     int
+	IPv4    *IPv4NetworkInterfaceSetConfiguration
+	IPv6    *IPv6NetworkInterfaceSetConfiguration
+}
+
+type IPv4NetworkInterfaceSetConfiguration struct {
+	Enabled bool
+	Manual  []PrefixedIPAddress
+	DHCP    bool
+}
+
+type IPv6NetworkInterfaceSetConfiguration struct {
+	Enabled            bool
+	AcceptRouterAdvert bool
+	Manual             []PrefixedIPAddress
+	DHCP               string
+}
+
+type SetNetworkInterfacesRequest struct {
+	XMLName          string                      `xml:"tds:SetNetworkInterfaces"`
+	InterfaceToken   string                      `xml:"tds:InterfaceToken"`
+	NetworkInterface setNetworkInterfaceSettings `xml:"tds:NetworkInterface"`
+}
+
+type setNetworkInterfaceSettings struct {
+	Enabled *bool                     `xml:"tt:Enabled,omitempty"`
+	Link    *setConnectionSetting     `xml:"tt:Link,omitempty"`
+	MTU     int                       `xml:"tt:MTU,omitempty"`
+	IPv4    *setIPv4InterfaceSettings `xml:"tt:IPv4,omitempty"`
+	IPv6    *setIPv6InterfaceSettings `xml:"tt:IPv6,omitempty"`
+}
+
+type setConnectionSetting struct {
+	AutoNegotiation bool   `xml:"tt:AutoNegotiation"`
+	Speed           int    `xml:"tt:Speed"`
+	Duplex          string `xml:"tt:Duplex"`
+}
+
+type setPrefixedIPAddress struct {
+	Address      string `xml:"tt:Address"`
+	PrefixLength int    `xml:"tt:PrefixLength"`
+}
+
+type setIPv4InterfaceSettings struct {
+	Enabled bool                   `xml:"tt:Enabled"`
+	Manual  []setPrefixedIPAddress `xml:"tt:Manual"`
+	DHCP    bool                   `xml:"tt:DHCP"`
+}
+
+type setIPv6InterfaceSettings struct {
+	Enabled            bool                   `xml:"tt:Enabled"`
+	AcceptRouterAdvert bool                   `xml:"tt:AcceptRouterAdvert"`
+	Manual             []setPrefixedIPAddress `xml:"tt:Manual"`
+	DHCP               string                 `xml:"tt:DHCP,omitempty"`
+}
+
+type SetNetworkInterfacesResponse struct {
+	XMLName      string `xml:"Envelope"`
+	RebootNeeded bool   `xml:"Body>SetNetworkInterfacesResponse>RebootNeeded"`
+}
+
+func toSetPrefixedIPAddresses(addresses []PrefixedIPAddress) []setPrefixedIPAddress {
+	var result []setPrefixedIPAddress
+	for _, address := range addresses {
+		result = append(result, setPrefixedIPAddress{
+			Address:      address.Address,
+			PrefixLength: address.PrefixLength,
+		})
+	}
+	return result
+}
+
+// 获取设备所有网卡的配置
+func (device *OnvifDevice) GetNetworkInterfaces() ([]NetworkInterface, error) {
+	var request NetworkInterfacesRequest
+	response := &NetworkInterfacesResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetNetworkInterfaces", request, response)
+	if err != nil {
+		log.Println("GetNetworkInterfaces fail", err)
+		return nil, err
+	}
+
+	return response.NetworkInterfaces, nil
+}
+
+// 修改网卡配置，返回值表示设备是否需要重启才能生效
+func (device *OnvifDevice) SetNetworkInterfaces(interfaceToken string, config NetworkInterfaceSetConfiguration) (bool, error) {
+	if interfaceToken == "" {
+		return false, errors.New("interface token is empty")
+	}
+
+	request := SetNetworkInterfacesRequest{InterfaceToken: interfaceToken}
+	settings := &request.NetworkInterface
+	settings.Enabled = config.Enabled
+	settings.MTU = config.MTU
+	if config.Link != nil {
+		settings.Link = &setConnectionSetting{
+			AutoNegotiation: config.Link.AutoNegotiation,
+			Speed:           config.Link.Speed,
+			Duplex:          config.Link.Duplex,
+		}
+	}
+	if config.IPv4 != nil {
+		for _, address := range config.IPv4.Manual {
+			if ip := net.ParseIP(address.Address); ip == nil || ip.To4() == nil {
+				return false, fmt.Errorf("invalid IPv4 address %q", address.Address)
+			}
+		}
+		settings.IPv4 = &setIPv4InterfaceSettings{
+			Enabled: config.IPv4.Enabled,
+			Manual:  toSetPrefixedIPAddresses(config.IPv4.Manual),
+			DHCP:    config.IPv4.DHCP,
+		}
+	}
+	if config.IPv6 != nil {
+		for _, address := range config.IPv6.Manual {
+			if ip := net.ParseIP(address.Address); ip == nil || ip.To4() != nil {
+				return false, fmt.Errorf("invalid IPv6 address %q", address.Address)
+			}
+		}
+		settings.IPv6 = &setIPv6InterfaceSettings{
+			Enabled:            config.IPv6.Enabled,
+			AcceptRouterAdvert: config.IPv6.AcceptRouterAdvert,
+			Manual:             toSetPrefixedIPAddresses(config.IPv6.Manual),
+			DHCP:               config.IPv6.DHCP,
+		}
+	}
+
+	response := &SetNetworkInterfacesResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/SetNetworkInterfaces", request, response)
+	if err != nil {
+		log.Println("SetNetworkInterfaces fail", err)
+		return false, err
+	}
+
+	return response.RebootNeeded, nil
+}
+
+// 修改设备IPv4地址并在新地址上重新连接。interfaceToken为空时使用第一个网卡。
+// 修改前记录设备序列号，新地址上应答的序列号一致才认为切换成功。
+// 失败时只恢复本地的DeviceIp，不会撤销设备上已生效的地址设置。
+// 很多设备应用新地址后不再应答修改请求，此时按网络错误处理，继续在新地址上等待；
+// 这种情况下无法得知设备是否需要重启，超时返回的错误会提示可能需要重启设备
+func (device *OnvifDevice) ChangeIPAddress(interfaceToken, newIp string, prefixLength int, timeout time.Duration) error {
+	if ip := net.ParseIP(newIp); ip == nil || ip.To4() == nil {
+		return fmt.Errorf("invalid IPv4 address %q", newIp)
+	}
+	if prefixLength < 1 || prefixLength > 32 {
+		return fmt.Errorf("invalid IPv4 prefix length %d", prefixLength)
+	}
+
+	before, err := device.GetDeviceInformation()
+	if err != nil {
+		return err
+	}
+
+	interfaces, err := device.GetNetworkInterfaces()
+	if err != nil {
+		return err
+	}
+	if len(interfaces) == 0 {
+		return errors.New("the device has no network interface")
+	}
+	if interfaceToken == "" {
+		interfaceToken = interfaces[0].Token
+	}
+
+	rebootNeeded, err := device.SetNetworkInterfaces(interfaceToken, NetworkInterfaceSetConfiguration{
+		IPv4: &IPv4NetworkInterfaceSetConfiguration{
+			Enabled: true,
+			Manual:  []PrefixedIPAddress{{Address: newIp, PrefixLength: prefixLength}},
+			DHCP:    false,
+		},
+	})
+	responseLost := false
+	if err != nil {
+		if isDeviceResponse(err) {
+			return err
+		}
+		log.Println("ChangeIPAddress: no response to SetNetworkInterfaces, waiting on new address", err)
+		responseLost = true
+	}
+
+	if rebootNeeded {
+		log.Println("ChangeIPAddress: reboot needed")
+		if _, err := device.SystemReboot(); err != nil {
+			return err
+		}
+	}
+
+	// DeviceIp可能带端口，新地址沿用原端口
+	oldIp := device.DeviceIp
+	if _, port, err := net.SplitHostPort(oldIp); err == nil {
+		device.DeviceIp = net.JoinHostPort(newIp, port)
+	} else {
+		device.DeviceIp = newIp
+	}
+	device.invalidateCache()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		after, err := device.GetDeviceInformation()
+		if err == nil {
+			if after.SerialNumber != before.SerialNumber {
+				device.DeviceIp = oldIp
+				return fmt.Errorf("device at %s reports serial number %q, expected %q",
+					newIp, after.SerialNumber, before.SerialNumber)
+			}
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			device.DeviceIp = oldIp
+			if responseLost {
+				return fmt.Errorf("device not reachable at %s: %v (SetNetworkInterfaces got no response, "+
+					"the device may require a reboot to apply the new address)", newIp, err)
+			}
+			return fmt.Errorf("device not reachable at %s: %v", newIp, err)
+		}
+		time.Sleep(pollInterval)
+	}
+}
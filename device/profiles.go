@@ -0,0 +1,244 @@
+package device
+
+import (
+	"errors"
+	"log"
+)
+
+type CreateProfileRequest struct {
+	XMLName string `xml:"trt:CreateProfile"`
+	Name    string `xml:"trt:Name"`
+	Token   string `xml:"trt:Token,omitempty"`
+}
+
+type CreateProfileResponse struct {
+	XMLName string  `xml:"Envelope"`
+	Profile Profile `xml:"Body>CreateProfileResponse>Profile"`
+}
+
+type DeleteProfileRequest struct {
+	XMLName      string `xml:"trt:DeleteProfile"`
+	ProfileToken string `xml:"trt:ProfileToken"`
+}
+
+type AddVideoSourceConfigurationRequest struct {
+	XMLName            string `xml:"trt:AddVideoSourceConfiguration"`
+	ProfileToken       string `xml:"trt:ProfileToken"`
+	ConfigurationToken string `xml:"trt:ConfigurationToken"`
+}
+
+type RemoveVideoSourceConfigurationRequest struct {
+	XMLName      string `xml:"trt:RemoveVideoSourceConfiguration"`
+	ProfileToken string `xml:"trt:ProfileToken"`
+}
+
+type AddVideoEncoderConfigurationRequest struct {
+	XMLName            string `xml:"trt:AddVideoEncoderConfiguration"`
+	ProfileToken       string `xml:"trt:ProfileToken"`
+	ConfigurationToken string `xml:"trt:ConfigurationToken"`
+}
+
+type RemoveVideoEncoderConfigurationRequest struct {
+	XMLName      string `xml:"trt:RemoveVideoEncoderConfiguration"`
+	ProfileToken string `xml:"trt:ProfileToken"`
+}
+
+type AddAudioSourceConfigurationRequest struct {
+	XMLName            string `xml:"trt:AddAudioSourceConfiguration"`
+	ProfileToken       string `xml:"trt:ProfileToken"`
+	ConfigurationToken string `xml:"trt:ConfigurationToken"`
+}
+
+type RemoveAudioSourceConfigurationRequest struct {
+	XMLName      string `xml:"trt:RemoveAudioSourceConfiguration"`
+	ProfileToken string `xml:"trt:ProfileToken"`
+}
+
+type AddAudioEncoderConfigurationRequest struct {
+	XMLName            string `xml:"trt:AddAudioEncoderConfiguration"`
+	ProfileToken       string `xml:"trt:ProfileToken"`
+	ConfigurationToken string `xml:"trt:ConfigurationToken"`
+}
+
+type RemoveAudioEncoderConfigurationRequest struct {
+	XMLName      string `xml:"trt:RemoveAudioEncoderConfiguration"`
+	ProfileToken string `xml:"trt:ProfileToken"`
+}
+
+type AddPTZConfigurationRequest struct {
+	XMLName            string `xml:"trt:AddPTZConfiguration"`
+	ProfileToken       string `xml:"trt:ProfileToken"`
+	ConfigurationToken string `xml:"trt:ConfigurationToken"`
+}
+
+type RemovePTZConfigurationRequest struct {
+	XMLName      string `xml:"trt:RemovePTZConfiguration"`
+	ProfileToken string `xml:"trt:ProfileToken"`
+}
+
+type AddVideoAnalyticsConfigurationRequest struct {
+	XMLName            string `xml:"trt:AddVideoAnalyticsConfiguration"`
+	ProfileToken       string `xml:"trt:ProfileToken"`
+	ConfigurationToken string `xml:"trt:ConfigurationToken"`
+}
+
+type RemoveVideoAnalyticsConfigurationRequest struct {
+	XMLName      string `xml:"trt:RemoveVideoAnalyticsConfiguration"`
+	ProfileToken string `xml:"trt:ProfileToken"`
+}
+
+type AddMetadataConfigurationRequest struct {
+	XMLName            string `xml:"trt:AddMetadataConfiguration"`
+	ProfileToken       string `xml:"trt:ProfileToken"`
+	ConfigurationToken string `xml:"trt:ConfigurationToken"`
+}
+
+type RemoveMetadataConfigurationRequest struct {
+	XMLName      string `xml:"trt:RemoveMetadataConfiguration"`
+	ProfileToken string `xml:"trt:ProfileToken"`
+}
+
+type ProfileEmptyResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+func (device *OnvifDevice) callMediaMethod(action string, request, response interface{}) error {
+	mediaAddr, err := device.mediaServiceAddr()
+	if err != nil {
+		return err
+	}
+
+	err = device.callMethod(mediaAddr, "http://www.onvif.org/ver10/media/wsdl/"+action, request, response)
+	if err != nil {
+		log.Println(action, "fail", err)
+		return err
+	}
+	return nil
+}
+
+// 修改媒体文件的组成，成功后清除缓存的媒体文件
+func (device *OnvifDevice) changeProfile(action, profileToken string, request interface{}) error {
+	if profileToken == "" {
+		return errors.New("profile token is empty")
+	}
+
+	if err := device.callMediaMethod(action, request, &ProfileEmptyResponse{}); err != nil {
+		return err
+	}
+
+	device.Profile = nil
+	return nil
+}
+
+func (device *OnvifDevice) addConfiguration(action, profileToken, configurationToken string, request interface{}) error {
+	if configurationToken == "" {
+		return errors.New("configuration token is empty")
+	}
+	return device.changeProfile(action, profileToken, request)
+}
+
+// 创建空的媒体文件，token为空时由设备分配。新媒体文件需要再添加各项配置才能取流
+func (device *OnvifDevice) CreateProfile(name, token string) (*Profile, error) {
+	if name == "" {
+		return nil, errors.New("profile name is empty")
+	}
+
+	request := CreateProfileRequest{Name: name, Token: token}
+	response := &CreateProfileResponse{}
+	if err := device.callMediaMethod("CreateProfile", request, response); err != nil {
+		return nil, err
+	}
+
+	device.Profile = nil
+	return &response.Profile, nil
+}
+
+// 删除媒体文件，设备预置的固定媒体文件不能删除
+func (device *OnvifDevice) DeleteProfile(profileToken string) error {
+	request := DeleteProfileRequest{ProfileToken: profileToken}
+	return device.changeProfile("DeleteProfile", profileToken, request)
+}
+
+// 向媒体文件添加视频源配置
+func (device *OnvifDevice) AddVideoSourceConfiguration(profileToken, configurationToken string) error {
+	request := AddVideoSourceConfigurationRequest{ProfileToken: profileToken, ConfigurationToken: configurationToken}
+	return device.addConfiguration("AddVideoSourceConfiguration", profileToken, configurationToken, request)
+}
+
+// 从媒体文件移除视频源配置
+func (device *OnvifDevice) RemoveVideoSourceConfiguration(profileToken string) error {
+	request := RemoveVideoSourceConfigurationRequest{ProfileToken: profileToken}
+	return device.changeProfile("RemoveVideoSourceConfiguration", profileToken, request)
+}
+
+// 向媒体文件添加视频编码配置，需要先添加视频源配置
+func (device *OnvifDevice) AddVideoEncoderConfiguration(profileToken, configurationToken string) error {
+	request := AddVideoEncoderConfigurationRequest{ProfileToken: profileToken, ConfigurationToken: configurationToken}
+	return device.addConfiguration("AddVideoEncoderConfiguration", profileToken, configurationToken, request)
+}
+
+// 从媒体文件移除视频编码配置
+func (device *OnvifDevice) RemoveVideoEncoderConfiguration(profileToken string) error {
+	request := RemoveVideoEncoderConfigurationRequest{ProfileToken: profileToken}
+	return device.changeProfile("RemoveVideoEncoderConfiguration", profileToken, request)
+}
+
+// 向媒体文件添加音频源配置
+func (device *OnvifDevice) AddAudioSourceConfiguration(profileToken, configurationToken string) error {
+	request := AddAudioSourceConfigurationRequest{ProfileToken: profileToken, ConfigurationToken: configurationToken}
+	return device.addConfiguration("AddAudioSourceConfiguration", profileToken, configurationToken, request)
+}
+
+// 从媒体文件移除音频源配置
+func (device *OnvifDevice) RemoveAudioSourceConfiguration(profileToken string) error {
+	request := RemoveAudioSourceConfigurationRequest{ProfileToken: profileToken}
+	return device.changeProfile("RemoveAudioSourceConfiguration", profileToken, request)
+}
+
+// 向媒体文件添加音频编码配置，需要先添加音频源配置
+func (device *OnvifDevice) AddAudioEncoderConfiguration(profileToken, configurationToken string) error {
+	request := AddAudioEncoderConfigurationRequest{ProfileToken: profileToken, ConfigurationToken: configurationToken}
+	return device.addConfiguration("AddAudioEncoderConfiguration", profileToken, configurationToken, request)
+}
+
+// 从媒体文件移除音频编码配置
+func (device *OnvifDevice) RemoveAudioEncoderConfiguration(profileToken string) error {
+	request := RemoveAudioEncoderConfigurationRequest{ProfileToken: profileToken}
+	return device.changeProfile("RemoveAudioEncoderConfiguration", profileToken, request)
+}
+
+// 向媒体文件添加云台配置
+func (device *OnvifDevice) AddPTZConfiguration(profileToken, configurationToken string) error {
+	request := AddPTZConfigurationRequest{ProfileToken: profileToken, ConfigurationToken: configurationToken}
+	return device.addConfiguration("AddPTZConfiguration", profileToken, configurationToken, request)
+}
+
+// 从媒体文件移除云台配置
+func (device *OnvifDevice) RemovePTZConfiguration(profileToken string) error {
+	request := RemovePTZConfigurationRequest{ProfileToken: profileToken}
+	return device.changeProfile("RemovePTZConfiguration", profileToken, request)
+}
+
+// 向媒体文件添加视频分析配置
+func (device *OnvifDevice) AddVideoAnalyticsConfiguration(profileToken, configurationToken string) error {
+	request := AddVideoAnalyticsConfigurationRequest{ProfileToken: profileToken, ConfigurationToken: configurationToken}
+	return device.addConfiguration("AddVideoAnalyticsConfiguration", profileToken, configurationToken, request)
+}
+
+// 从媒体文件移除视频分析配置
+func (device *OnvifDevice) RemoveVideoAnalyticsConfiguration(profileToken string) error {
+	request := RemoveVideoAnalyticsConfigurationRequest{ProfileToken: profileToken}
+	return device.changeProfile("RemoveVideoAnalyticsConfiguration", profileToken, request)
+}
+
+// 向媒体文件添加元数据配置
+func (device *OnvifDevice) AddMetadataConfiguration(profileToken, configurationToken string) error {
+	request := AddMetadataConfigurationRequest{ProfileToken: profileToken, ConfigurationToken: configurationToken}
+	return device.addConfiguration("AddMetadataConfiguration", profileToken, configurationToken, request)
+}
+
+// 从媒体文件移除元数据配置
+func (device *OnvifDevice) RemoveMetadataConfiguration(profileToken string) error {
+	request := RemoveMetadataConfigurationRequest{ProfileToken: profileToken}
+	return device.changeProfile("RemoveMetadataConfiguration", profileToken, request)
+}
@@ -0,0 +1,188 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// 继电器模式
+const (
+	RelayModeMonostable = "Monostable"
+	RelayModeBistable   = "Bistable"
+)
+
+// 继电器空闲状态
+const (
+	RelayIdleStateClosed = "closed"
+	RelayIdleStateOpen   = "open"
+)
+
+// 继电器逻辑状态
+const (
+	RelayLogicalStateActive   = "active"
+	RelayLogicalStateInactive = "inactive"
+)
+
+type RelayOutput struct {
+	Token      string                `xml:"token,attr"`
+	Properties RelayOutputProperties `xml:"Properties"`
+}
+
+type RelayOutputProperties struct {
+	Mode      string `xml:"Mode"`
+	DelayTime string `xml:"DelayTime"`
+	IdleState string `xml:"IdleState"`
+}
+
+// 继电器设置，Monostable模式下DelayTime后自动恢复空闲状态
+type RelayOutputSettings struct {
+	Mode      string
+	DelayTime time.Duration
+	IdleState string
+}
+
+type RelayOutputsRequest struct {
+	XMLName string `xml:"tds:GetRelayOutputs"`
+}
+
+type RelayOutputsResponse struct {
+	XMLName      string        `xml:"Envelope"`
+	RelayOutputs []RelayOutput `xml:"Body>GetRelayOutputsResponse>RelayOutputs"`
+}
+
+type SetRelayOutputSettingsRequest struct {
+	XMLName          string                   `xml:"tds:SetRelayOutputSettings"`
+	RelayOutputToken string                   `xml:"tds:RelayOutputToken"`
+	Properties       setRelayOutputProperties `xml:"tds:Properties"`
+}
+
+type setRelayOutputProperties struct {
+	Mode      string `xml:"tt:Mode"`
+	DelayTime string `xml:"tt:DelayTime"`
+	IdleState string `xml:"tt:IdleState"`
+}
+
+type SetRelayOutputSettingsResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+type SetRelayOutputStateRequest struct {
+	XMLName          string `xml:"tds:SetRelayOutputState"`
+	RelayOutputToken string `xml:"tds:RelayOutputToken"`
+	LogicalState     string `xml:"tds:LogicalState"`
+}
+
+type SetRelayOutputStateResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+// 解析后的继电器设置
+func (relay *RelayOutput) Settings() (*RelayOutputSettings, error) {
+	delayTime, err := parseDuration(relay.Properties.DelayTime)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RelayOutputSettings{
+		Mode:      relay.Properties.Mode,
+		DelayTime: delayTime,
+		IdleState: relay.Properties.IdleState,
+	}, nil
+}
+
+// 获取设备继电器输出列表
+func (device *OnvifDevice) GetRelayOutputs() ([]RelayOutput, error) {
+	var request RelayOutputsRequest
+	response := &RelayOutputsResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetRelayOutputs", request, response)
+	if err != nil {
+		log.Println("GetRelayOutputs fail", err)
+		return nil, err
+	}
+
+	return response.RelayOutputs, nil
+}
+
+// 设置继电器模式、延时和空闲状态
+func (device *OnvifDevice) SetRelayOutputSettings(token string, settings RelayOutputSettings) error {
+	if token == "" {
+		return errors.New("relay output token is empty")
+	}
+	if settings.Mode != RelayModeMonostable && settings.Mode != RelayModeBistable {
+		return errors.New("relay mode must be Monostable or Bistable")
+	}
+	if settings.IdleState != RelayIdleStateClosed && settings.IdleState != RelayIdleStateOpen {
+		return errors.New("relay idle state must be closed or open")
+	}
+
+	request := SetRelayOutputSettingsRequest{
+		RelayOutputToken: token,
+		Properties: setRelayOutputProperties{
+			Mode:      settings.Mode,
+			DelayTime: formatDuration(settings.DelayTime),
+			IdleState: settings.IdleState,
+		},
+	}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/SetRelayOutputSettings", request, &SetRelayOutputSettingsResponse{})
+	if err != nil {
+		log.Println("SetRelayOutputSettings fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 设置继电器逻辑状态，active表示触发
+func (device *OnvifDevice) SetRelayOutputState(token string, active bool) error {
+	if token == "" {
+		return errors.New("relay output token is empty")
+	}
+
+	request := SetRelayOutputStateRequest{
+		RelayOutputToken: token,
+		LogicalState:     RelayLogicalStateInactive,
+	}
+	if active {
+		request.LogicalState = RelayLogicalStateActive
+	}
+
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/SetRelayOutputState", request, &SetRelayOutputStateResponse{})
+	if err != nil {
+		log.Println("SetRelayOutputState fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 恢复继电器失败时的重试次数和间隔
+const (
+	relayResetRetries  = 3
+	relayResetInterval = time.Second
+)
+
+// 触发继电器并在duration后恢复，用于开门、鸣笛等场景。
+// 恢复由本端发出，与继电器的Monostable延时设置无关。恢复失败时重试，仍失败则返回的错误说明继电器仍处于触发状态
+func (device *OnvifDevice) PulseRelayOutput(token string, duration time.Duration) error {
+	if err := device.SetRelayOutputState(token, true); err != nil {
+		return err
+	}
+
+	time.Sleep(duration)
+
+	var err error
+	for i := 0; i < relayResetRetries; i++ {
+		if i > 0 {
+			time.Sleep(relayResetInterval)
+		}
+		if err = device.SetRelayOutputState(token, false); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("relay output %s left active after %d attempts to deactivate: %w", token, relayResetRetries, err)
+}
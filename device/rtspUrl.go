@@ -0,0 +1,94 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// RTSP默认端口
+const (
+	rtspDefaultPort  = 554
+	rtspsDefaultPort = 322
+)
+
+// NAT映射后的对外地址，Host为空或Port为0时保留流地址中的原值
+type NATMapping struct {
+	Host string
+	Port int
+}
+
+// 规范化设备返回的流地址：去掉首尾空白，还原XML转义的&amp;，补全缺省端口
+func NormalizeRtspUrl(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	raw = strings.Replace(raw, "&amp;", "&", -1)
+	if raw == "" {
+		return nil, errors.New("rtsp url is empty")
+	}
+
+	uri, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	uri.Scheme = strings.ToLower(uri.Scheme)
+	var defaultPort int
+	switch uri.Scheme {
+	case "rtsp":
+		defaultPort = rtspDefaultPort
+	case "rtsps":
+		defaultPort = rtspsDefaultPort
+	default:
+		return nil, fmt.Errorf("not a rtsp url: %s", raw)
+	}
+
+	if uri.Hostname() == "" {
+		return nil, fmt.Errorf("rtsp url has no host: %s", raw)
+	}
+	if uri.Port() == "" {
+		uri.Host = net.JoinHostPort(uri.Hostname(), strconv.Itoa(defaultPort))
+	}
+
+	return uri, nil
+}
+
+// 生成可直接交给ffmpeg、GStreamer等播放器的流地址：嵌入转义后的用户名和密码，
+// nat不为nil时替换主机和端口
+func RtspUrlWithCredentials(raw, user, passwd string, nat *NATMapping) (string, error) {
+	uri, err := NormalizeRtspUrl(raw)
+	if err != nil {
+		return "", err
+	}
+
+	if nat != nil {
+		host, port := uri.Hostname(), uri.Port()
+		if nat.Host != "" {
+			host = nat.Host
+		}
+		if nat.Port > 0 {
+			port = strconv.Itoa(nat.Port)
+		}
+		uri.Host = net.JoinHostPort(host, port)
+	}
+
+	if user != "" && passwd != "" {
+		uri.User = url.UserPassword(user, passwd)
+	} else if user != "" {
+		uri.User = url.User(user)
+	}
+
+	return uri.String(), nil
+}
+
+// 第一个媒体文件带设备账号的单播流地址
+func (device *OnvifDevice) GetPlayerMediaUri(nat *NATMapping) (string, error) {
+	mediaUri, err := device.GetMediaUri()
+	if err != nil {
+		return "", err
+	}
+
+	return RtspUrlWithCredentials(mediaUri, device.User, device.Passwd, nat)
+}
@@ -4,6 +4,9 @@ import (
 	"crypto/sha1"
 	"encoding/base64"
 	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/elgs/gostrgen"
@@ -38,12 +41,16 @@ const (
 )
 
 func NewSecurity(username, passwd string) Security {
+	return newSecurityAt(username, passwd, time.Now())
+}
 
+// 按指定时间生成UsernameToken，设备时钟不准时用设备时间作为Created，避免令牌被判为过期
+func newSecurityAt(username, passwd string, now time.Time) Security {
 	charsToGenerate := 32
 	charSet := gostrgen.Lower | gostrgen.Digit
 
 	nonceSeq, _ := gostrgen.RandGen(charsToGenerate, charSet, "", "")
-	created := time.Now().UTC().Format(time.RFC3339Nano)
+	created := now.UTC().Format(time.RFC3339Nano)
 	auth := Security{
 		Auth: wsAuth{
 			Username: username,
@@ -70,3 +77,47 @@ func generateToken(Username string, Nonce string, Created string, Password strin
 
 	return base64.StdEncoding.EncodeToString(hasher.Sum(nil))
 }
+
+// 根据401应答中的摘要认证挑战生成Authorization头，设备未给出摘要挑战时返回空串
+func (device *OnvifDevice) digestAuthorization(resp *http.Response, method, uri string) string {
+	params := DigestAuthParams(resp)
+	if params == nil {
+		return ""
+	}
+
+	realm := params["realm"]
+	nonceHeader := params["nonce"]
+	ha1 := getMD5(fmt.Sprintf("%s:%s:%s", device.User, realm, device.Passwd))
+	ha2 := getMD5(fmt.Sprintf("%s:%s", method, uri))
+
+	header := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s"`,
+		device.User, realm, nonceHeader, uri)
+
+	// qop可能为"auth,auth-int"，DigestAuthParams按逗号拆分后只保留了第一个值
+	if qop := params["qop"]; qop != "" {
+		qop = "auth"
+		cnonce := getCnonce()
+		response := getMD5(fmt.Sprintf("%s:%s:%s:%s:%s:%s", ha1, nonceHeader, nc, cnonce, qop, ha2))
+		header += fmt.Sprintf(`, response="%s", algorithm=MD5, qop=%s, nc=%s, cnonce="%s"`,
+			response, qop, nc, cnonce)
+	} else {
+		response := getMD5(fmt.Sprintf("%s:%s:%s", ha1, nonceHeader, ha2))
+		header += fmt.Sprintf(`, response="%s", algorithm=MD5`, response)
+	}
+
+	if opaque := params["opaque"]; opaque != "" {
+		header += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+
+	return header
+}
+
+// 根据401应答的认证挑战生成Authorization头，支持Basic和Digest
+func (device *OnvifDevice) httpAuthorization(resp *http.Response, method, uri string) string {
+	challenge := resp.Header.Get("Www-Authenticate")
+	if strings.HasPrefix(strings.ToLower(challenge), "basic") {
+		credentials := base64.StdEncoding.EncodeToString([]byte(device.User + ":" + device.Passwd))
+		return "Basic " + credentials
+	}
+	return device.digestAuthorization(resp, method, uri)
+}
@@ -0,0 +1,60 @@
+package device
+
+import (
+	"log"
+)
+
+// 服务命名空间
+const (
+	DeviceServiceNamespace           = "http://www.onvif.org/ver10/device/wsdl"
+	MediaServiceNamespace            = "http://www.onvif.org/ver10/media/wsdl"
+	PTZServiceNamespace              = "http://www.onvif.org/ver20/ptz/wsdl"
+	AdvancedSecurityServiceNamespace = "http://www.onvif.org/ver10/advancedsecurity/wsdl"
+)
+
+type ServicesRequest struct {
+	XMLName           string `xml:"tds:GetServices"`
+	IncludeCapability bool   `xml:"tds:IncludeCapability"`
+}
+
+type ServicesResponse struct {
+	XMLName string    `xml:"Envelope"`
+	Service []Service `xml:"Body>GetServicesResponse>Service"`
+}
+
+type Service struct {
+	Namespace string                  `xml:"Namespace"`
+	XAddr     string                  `xml:"XAddr"`
+	Version   SystemSupportedVersions `xml:"Version"`
+}
+
+// 获取设备提供的服务及其地址并缓存，GetCapabilities中没有的服务(如高级安全)需要由此获取
+func (device *OnvifDevice) GetServices() ([]Service, error) {
+	var request ServicesRequest
+	response := &ServicesResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetServices", request, response)
+	if err != nil {
+		log.Println("GetServices fail", err)
+		return nil, err
+	}
+
+	device.Services = append([]Service{}, response.Service...)
+	return response.Service, nil
+}
+
+// 按命名空间查找服务地址，设备不提供该服务时返回空串。优先使用缓存的服务列表
+func (device *OnvifDevice) serviceAddr(namespace string) (string, error) {
+	if device.Services == nil {
+		if _, err := device.GetServices(); err != nil {
+			return "", err
+		}
+	}
+
+	for _, service := range device.Services {
+		if service.Namespace == namespace {
+			return service.XAddr, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,124 @@
+package device
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+)
+
+type SnapshotUriRequest struct {
+	XMLName      string `xml:"trt:GetSnapshotUri"`
+	ProfileToken string `xml:"trt:ProfileToken"`
+}
+
+type SnapshotUriResponse struct {
+	XMLName  string   `xml:"Envelope"`
+	MediaUri MediaUri `xml:"Body>GetSnapshotUriResponse>MediaUri"`
+}
+
+// 获取媒体文件的JPEG截图地址
+func (device *OnvifDevice) GetSnapshotUri(profileToken string) (*MediaUri, error) {
+	mediaAddr, err := device.mediaServiceAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	request := SnapshotUriRequest{ProfileToken: profileToken}
+	response := &SnapshotUriResponse{}
+	err = device.callMethod(mediaAddr, "http://www.onvif.org/ver10/media/wsdl/GetSnapshotUri", request, response)
+	if err != nil {
+		log.Println("GetSnapshotUri fail", err)
+		return nil, err
+	}
+
+	if response.MediaUri.Uri == "" {
+		return nil, errors.New("GetSnapshotUri returned an empty uri")
+	}
+	return &response.MediaUri, nil
+}
+
+// 下载截图地址上的JPEG图片，设备要求认证时按Basic或Digest方式重试
+func (device *OnvifDevice) fetchSnapshot(uri string) ([]byte, error) {
+	client := &http.Client{Timeout: requestTimeout}
+
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		authorization := device.httpAuthorization(resp, "GET", req.URL.RequestURI())
+		if authorization == "" {
+			return nil, errors.New("snapshot requires authentication but gave no supported challenge")
+		}
+
+		req, err = http.NewRequest("GET", uri, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", authorization)
+
+		resp, err = client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("snapshot: unexpected status code %d", resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// 部分设备不返回Content-Type或格式不合法，只有此时才按内容判断
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		mediaType = http.DetectContentType(data)
+	}
+	if mediaType != "image/jpeg" && mediaType != "image/jpg" {
+		return nil, fmt.Errorf("snapshot is not a jpeg image: %s", mediaType)
+	}
+
+	return data, nil
+}
+
+// 下载媒体文件的JPEG截图
+func (device *OnvifDevice) DownloadSnapshot(profileToken string) ([]byte, error) {
+	mediaUri, err := device.GetSnapshotUri(profileToken)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := device.fetchSnapshot(mediaUri.Uri)
+	if err != nil {
+		log.Println("DownloadSnapshot fail", err)
+		return nil, err
+	}
+	return data, nil
+}
+
+// 下载媒体文件的截图并解码
+func (device *OnvifDevice) DownloadSnapshotImage(profileToken string) (image.Image, error) {
+	data, err := device.DownloadSnapshot(profileToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return jpeg.Decode(bytes.NewReader(data))
+}
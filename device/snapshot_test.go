@@ -0,0 +1,189 @@
+package device
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	testUser   = "admin"
+	testPasswd = "p@ss:word"
+	testRealm  = "camera"
+	testNonce  = "0123456789abcdef"
+)
+
+func md5Hex(text string) string {
+	sum := md5.Sum([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// 解析Authorization头中Digest后面的参数
+func parseDigestHeader(header string) map[string]string {
+	params := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(header, "Digest "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) == 2 {
+			params[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return params
+}
+
+func checkDigest(r *http.Request) bool {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Digest ") {
+		return false
+	}
+	params := parseDigestHeader(header)
+	ha1 := md5Hex(testUser + ":" + testRealm + ":" + testPasswd)
+	ha2 := md5Hex(r.Method + ":" + params["uri"])
+	expected := md5Hex(strings.Join([]string{ha1, testNonce, params["nc"], params["cnonce"], params["qop"], ha2}, ":"))
+	return params["username"] == testUser && params["uri"] == r.URL.RequestURI() && params["response"] == expected
+}
+
+func checkBasic(r *http.Request) bool {
+	user, passwd, ok := r.BasicAuth()
+	return ok && user == testUser && passwd == testPasswd
+}
+
+func testJPEG(t *testing.T) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 6))
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 6; y++ {
+			img.Set(x, y, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// 模拟设备：媒体服务返回截图地址，截图地址按scheme要求认证后返回body
+func newSnapshotServer(t *testing.T, scheme, contentType string, body []byte) (*httptest.Server, *OnvifDevice) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/onvif/media":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope" xmlns:trt="http://www.onvif.org/ver10/media/wsdl" xmlns:tt="http://www.onvif.org/ver10/schema">
+<env:Body><trt:GetSnapshotUriResponse><trt:MediaUri>
+<tt:Uri>%s/snapshot.jpg?channel=1&amp;size=full</tt:Uri>
+<tt:InvalidAfterConnect>false</tt:InvalidAfterConnect><tt:InvalidAfterReboot>false</tt:InvalidAfterReboot><tt:Timeout>PT0S</tt:Timeout>
+</trt:MediaUri></trt:GetSnapshotUriResponse></env:Body></env:Envelope>`, server.URL)
+		case "/snapshot.jpg":
+			authorized := false
+			switch scheme {
+			case "Digest":
+				authorized = checkDigest(r)
+				if !authorized {
+					w.Header().Set("WWW-Authenticate",
+						fmt.Sprintf(`Digest realm="%s", qop="auth", nonce="%s", opaque="xyz"`, testRealm, testNonce))
+				}
+			case "Basic":
+				authorized = checkBasic(r)
+				if !authorized {
+					w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm="%s"`, testRealm))
+				}
+			}
+			if !authorized {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if contentType != "" {
+				w.Header().Set("Content-Type", contentType)
+			} else {
+				// 阻止net/http按内容自动补上Content-Type
+				w.Header()["Content-Type"] = nil
+			}
+			w.Write(body)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	device := &OnvifDevice{User: testUser, Passwd: testPasswd, DeviceIp: strings.TrimPrefix(server.URL, "http://")}
+	device.Capabilities = &CapbilityResponse{}
+	device.Capabilities.Capabilities.Media.XAddr = server.URL + "/onvif/media"
+	return server, device
+}
+
+func TestDownloadSnapshotAuthentication(t *testing.T) {
+	jpegData := testJPEG(t)
+	for _, scheme := range []string{"Digest", "Basic"} {
+		t.Run(scheme, func(t *testing.T) {
+			server, device := newSnapshotServer(t, scheme, "image/jpeg", jpegData)
+			defer server.Close()
+
+			mediaUri, err := device.GetSnapshotUri("profile_1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := server.URL + "/snapshot.jpg?channel=1&size=full"; mediaUri.Uri != want {
+				t.Fatalf("snapshot uri %q, want %q", mediaUri.Uri, want)
+			}
+
+			data, err := device.fetchSnapshot(mediaUri.Uri)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, jpegData) {
+				t.Fatal("snapshot data mismatch")
+			}
+		})
+	}
+}
+
+func TestDownloadSnapshotRejectsNonJPEG(t *testing.T) {
+	jpegData := testJPEG(t)
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		ok          bool
+	}{
+		{"html", "text/html", []byte("<html><body>login</body></html>"), false},
+		{"html header with jpeg body", "text/html", jpegData, false},
+		{"missing header with html body", "", []byte("<html><body>login</body></html>"), false},
+		{"missing header with jpeg body", "", jpegData, true},
+		{"jpeg with parameters", "image/jpeg; charset=binary", jpegData, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, device := newSnapshotServer(t, "Basic", test.contentType, test.body)
+			defer server.Close()
+
+			_, err := device.DownloadSnapshot("profile_1")
+			if test.ok && err != nil {
+				t.Fatal(err)
+			}
+			if !test.ok && err == nil {
+				t.Fatal("non-jpeg snapshot accepted")
+			}
+		})
+	}
+}
+
+func TestDownloadSnapshotImage(t *testing.T) {
+	server, device := newSnapshotServer(t, "Digest", "image/jpeg", testJPEG(t))
+	defer server.Close()
+
+	img, err := device.DownloadSnapshotImage("profile_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 8 || bounds.Dy() != 6 {
+		t.Fatalf("image size %dx%d, want 8x6", bounds.Dx(), bounds.Dy())
+	}
+}
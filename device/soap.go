@@ -1,9 +1,19 @@
 package device
 
 import (
+	"bytes"
 	"encoding/xml"
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"time"
 
 	"github.com/beevik/etree"
 )
@@ -41,7 +51,7 @@ func buildSoapRoot() *etree.Document {
 	env.CreateAttr("xmlns:xenc", "http://www.w3.org/2001/04/xmlenc#")
 	env.CreateAttr("xmlns:wsc", "http://docs.oasis-open.org/ws-sx/ws-secureconversation/200512")
 	env.CreateAttr("xmlns:wsse", "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd")
-	env.CreateAttr("xmlns:xmime", "http://tempuri.org/xmime.xsd")
+	env.CreateAttr("xmlns:xmime", "http://www.w3.org/2005/05/xmlmime")
 	env.CreateAttr("xmlns:xop", "http://www.w3.org/2004/08/xop/include")
 	env.CreateAttr("xmlns:wsa5", "http://www.w3.org/2005/08/addressing")
 	env.CreateAttr("xmlns:wstop", "http://docs.oasis-open.org/wsn/t-1")
@@ -56,6 +66,8 @@ func buildSoapRoot() *etree.Document {
 	env.CreateAttr("xmlns:tptz", "http://www.onvif.org/ver20/ptz/wsdl")
 	env.CreateAttr("xmlns:tr2", "http://www.onvif.org/ver20/media/wsdl")
 	env.CreateAttr("xmlns:trt", "http://www.onvif.org/ver10/media/wsdl")
+	env.CreateAttr("xmlns:tas", "http://www.onvif.org/ver10/advancedsecurity/wsdl")
+	env.CreateAttr("xmlns:tns1", "http://www.onvif.org/ver10/topics")
 
 	return doc
 }
@@ -85,8 +97,12 @@ func (msg *SoapMessage) AddBodyContent(element *etree.Element) {
 }
 
 func (msg *SoapMessage) AddWSSecurity(user, passwd string) error {
+	return msg.addWSSecurityAt(user, passwd, time.Now())
+}
+
+func (msg *SoapMessage) addWSSecurityAt(user, passwd string, now time.Time) error {
 
-	auth := NewSecurity(user, passwd)
+	auth := newSecurityAt(user, passwd, now)
 	soapReq, err := xml.MarshalIndent(auth, "", "  ")
 	if err != nil {
 		return err
@@ -115,3 +131,283 @@ func (msg *SoapMessage) AddWSSecurity(user, passwd string) error {
 	*msg = SoapMessage(res)
 	return nil
 }
+
+// SOAP错误应答
+type soapFault struct {
+	XMLName string `xml:"Envelope"`
+	Code    string `xml:"Body>Fault>Code>Value"`
+	Subcode string `xml:"Body>Fault>Code>Subcode>Value"`
+	Reason  string `xml:"Body>Fault>Reason>Text"`
+}
+
+func (fault *soapFault) Error() string {
+	if fault.Subcode != "" {
+		return fmt.Sprintf("soap fault %s (%s): %s", fault.Code, fault.Subcode, fault.Reason)
+	}
+	return fmt.Sprintf("soap fault %s: %s", fault.Code, fault.Reason)
+}
+
+// 错误是否为设备不支持该操作的SOAP错误(ter:ActionNotSupported、ter:NotSupported)
+func isActionNotSupported(err error) bool {
+	fault, ok := err.(*soapFault)
+	if !ok {
+		return false
+	}
+	subcode := fault.Subcode
+	if i := strings.LastIndex(subcode, ":"); i >= 0 {
+		subcode = subcode[i+1:]
+	}
+	return subcode == "ActionNotSupported" || subcode == "NotSupported"
+}
+
+// 设备返回了非200且不是SOAP错误的HTTP应答
+type httpStatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (err *httpStatusError) Error() string {
+	return err.Message
+}
+
+// 错误是否来自设备的HTTP应答(SOAP错误、认证失败等)，而不是连接失败、超时等网络错误
+func isDeviceResponse(err error) bool {
+	switch err.(type) {
+	case *soapFault, *httpStatusError:
+		return true
+	}
+	return false
+}
+
+const requestTimeout = 30 * time.Second
+
+const soapContentType = "application/soap+xml; charset=utf-8"
+
+// 生成带WS-Security认证头的SOAP请求报文
+func (device *OnvifDevice) buildSoapRequest(request interface{}) (string, error) {
+	element, err := buildElement(request)
+	if err != nil {
+		log.Println("buildElement fail", err)
+		return "", errors.New("buildElement fail")
+	}
+
+	soap := NewEmptySOAP()
+	soap.AddBodyContent(element)
+	if device.User != "" {
+		if err := soap.addWSSecurityAt(device.User, device.Passwd, time.Now().Add(device.ClockOffset)); err != nil {
+			return "", err
+		}
+	}
+
+	return soap.String(), nil
+}
+
+// 向服务地址发送SOAP请求，401时按摘要认证重试，应答解析到response中
+func (device *OnvifDevice) callMethod(endpoint, action string, request, response interface{}) error {
+	_, err := device.callMethodWithAttachments(endpoint, action, request, response)
+	return err
+}
+
+// 同callMethod，另外返回MTOM应答中按Content-ID索引的附件
+func (device *OnvifDevice) callMethodWithAttachments(endpoint, action string, request, response interface{}) (map[string][]byte, error) {
+	if endpoint == "" {
+		return nil, errors.New("service address is empty")
+	}
+
+	soap, err := device.buildSoapRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	body, attachments, err := device.postSoap(endpoint, action, soapContentType, []byte(soap), requestTimeout)
+	if err != nil {
+		log.Println(action, "fail", err)
+		return nil, err
+	}
+
+	if response == nil {
+		return attachments, nil
+	}
+
+	if err := xml.Unmarshal(body, response); err != nil {
+		log.Println(action, "xml.Unmarshal fail", err)
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// MTOM请求中的附件，SOAP报文中用xop:Include href="cid:ContentID"引用
+type mtomAttachment struct {
+	ContentID   string
+	ContentType string
+	Data        []byte
+}
+
+// 以MTOM/XOP方式发送带附件的SOAP请求
+func (device *OnvifDevice) callMethodWithMTOM(endpoint, action string, request, response interface{}, attachments []mtomAttachment) error {
+	if endpoint == "" {
+		return errors.New("service address is empty")
+	}
+
+	soap, err := device.buildSoapRequest(request)
+	if err != nil {
+		return err
+	}
+
+	var payload bytes.Buffer
+	writer := multipart.NewWriter(&payload)
+
+	rootHeader := textproto.MIMEHeader{}
+	rootHeader.Set("Content-Type", `application/xop+xml; charset=UTF-8; type="application/soap+xml"`)
+	rootHeader.Set("Content-Transfer-Encoding", "8bit")
+	rootHeader.Set("Content-ID", "<soap@onvif>")
+	part, err := writer.CreatePart(rootHeader)
+	if err != nil {
+		return err
+	}
+	part.Write([]byte(soap))
+
+	for _, attachment := range attachments {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", attachment.ContentType)
+		header.Set("Content-Transfer-Encoding", "binary")
+		header.Set("Content-ID", "<"+attachment.ContentID+">")
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		part.Write(attachment.Data)
+	}
+	writer.Close()
+
+	contentType := fmt.Sprintf(`multipart/related; type="application/xop+xml"; start="<soap@onvif>"; start-info="application/soap+xml"; boundary="%s"`,
+		writer.Boundary())
+	body, _, err := device.postSoap(endpoint, action, contentType, payload.Bytes(), 0)
+	if err != nil {
+		log.Println(action, "fail", err)
+		return err
+	}
+
+	if response == nil {
+		return nil
+	}
+
+	if err := xml.Unmarshal(body, response); err != nil {
+		log.Println(action, "xml.Unmarshal fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// timeout为0时不限制请求时间，用于上传较大的附件
+func (device *OnvifDevice) postSoap(endpoint, action, contentType string, payload []byte, timeout time.Duration) ([]byte, map[string][]byte, error) {
+	client := &http.Client{Timeout: timeout}
+
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", endpoint, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Add("SOAPAction", `"`+action+`"`)
+		return req, nil
+	}
+
+	req, err := newRequest()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		authorization := device.digestAuthorization(resp, "POST", req.URL.RequestURI())
+		if authorization == "" {
+			return nil, nil, &httpStatusError{StatusCode: resp.StatusCode,
+				Message: "device requires authentication but gave no digest challenge"}
+		}
+
+		req, err = newRequest()
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Header.Set("Authorization", authorization)
+
+		resp, err = client.Do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer resp.Body.Close()
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var attachments map[string][]byte
+	if mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil &&
+		mediaType == "multipart/related" {
+		body, attachments, err = splitMultipart(body, params)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		fault := &soapFault{}
+		if xml.Unmarshal(body, fault) == nil && fault.Code != "" {
+			return nil, nil, fault
+		}
+		return nil, nil, &httpStatusError{StatusCode: resp.StatusCode,
+			Message: fmt.Sprintf("%s: unexpected status code %d", action, resp.StatusCode)}
+	}
+
+	return body, attachments, nil
+}
+
+// 拆分MTOM/XOP应答，返回SOAP根报文和按Content-ID(不含尖括号)索引的附件
+func splitMultipart(body []byte, params map[string]string) ([]byte, map[string][]byte, error) {
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, nil, errors.New("multipart response without boundary")
+	}
+	start := strings.Trim(params["start"], "<>")
+
+	var root []byte
+	attachments := map[string][]byte{}
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		data, err := ioutil.ReadAll(part)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		id := strings.Trim(part.Header.Get("Content-ID"), "<>")
+		if root == nil && (start == "" || id == start) {
+			root = data
+			continue
+		}
+		attachments[id] = data
+	}
+
+	if root == nil {
+		return nil, nil, errors.New("multipart response without soap part")
+	}
+
+	return root, attachments, nil
+}
@@ -0,0 +1,226 @@
+package device
+
+import (
+	"encoding/xml"
+	"errors"
+	"log"
+	"strings"
+)
+
+// 常用存储类型
+const (
+	StorageTypeNFS   = "NFS"
+	StorageTypeCIFS  = "CIFS"
+	StorageTypeCDMI  = "CDMI"
+	StorageTypeFTP   = "FTP"
+	StorageTypeLocal = "LocalStorage"
+)
+
+// 存储配置。ONVIF没有定义存储状态，厂商上报的状态等扩展信息原样保存在Data.Extension中，
+// 需要调用者自行解析Extension.InnerXML
+type StorageConfiguration struct {
+	Token string                   `xml:"token,attr"`
+	Data  StorageConfigurationData `xml:"Data"`
+}
+
+type StorageConfigurationData struct {
+	Type       string         `xml:"type,attr"`
+	LocalPath  string         `xml:"LocalPath"`
+	StorageUri string         `xml:"StorageUri"`
+	User       UserCredential `xml:"User"`
+	Region     string         `xml:"Region"`
+	Extension  RawXML         `xml:"Extension"`
+}
+
+// 访问网络存储的账号，GetStorageConfigurations不返回Password
+type UserCredential struct {
+	UserName string `xml:"UserName"`
+	Password string `xml:"Password"`
+}
+
+// 未解析的XML元素内容
+type RawXML struct {
+	InnerXML string `xml:",innerxml"`
+}
+
+// 厂商扩展中常见的状态元素名(小写)
+var storageStateElements = []string{"state", "status", "storagestatus", "storagestate", "hddstatus"}
+
+// 厂商扩展中的存储状态原值，按常见的状态元素名查找，只是尽力而为。
+// 没有这些元素时返回false，调用者需要自行解析Extension.InnerXML
+func (data *StorageConfigurationData) State() (string, bool) {
+	decoder := xml.NewDecoder(strings.NewReader("<Extension>" + data.Extension.InnerXML + "</Extension>"))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", false
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || !containsString(storageStateElements, strings.ToLower(start.Name.Local)) {
+			continue
+		}
+
+		var value string
+		if err := decoder.DecodeElement(&value, &start); err != nil {
+			return "", false
+		}
+		if value = strings.TrimSpace(value); value != "" {
+			return value, true
+		}
+	}
+}
+
+type setStorageConfigurationData struct {
+	Type       string             `xml:"type,attr"`
+	LocalPath  string             `xml:"tt:LocalPath,omitempty"`
+	StorageUri string             `xml:"tt:StorageUri,omitempty"`
+	User       *setUserCredential `xml:"tt:User,omitempty"`
+	Region     string             `xml:"tt:Region,omitempty"`
+}
+
+type setUserCredential struct {
+	UserName string `xml:"tt:UserName"`
+	Password string `xml:"tt:Password"`
+}
+
+// 存储位置，本地存储返回LocalPath，网络存储返回StorageUri
+func (data *StorageConfigurationData) Path() string {
+	if data.LocalPath != "" {
+		return data.LocalPath
+	}
+	return data.StorageUri
+}
+
+func (data *StorageConfigurationData) toSet() (setStorageConfigurationData, error) {
+	if data.Type == "" {
+		return setStorageConfigurationData{}, errors.New("storage type is empty")
+	}
+
+	result := setStorageConfigurationData{
+		Type:       data.Type,
+		LocalPath:  data.LocalPath,
+		StorageUri: data.StorageUri,
+		Region:     data.Region,
+	}
+	// GetStorageConfigurations不返回Password，只发送用户名会使很多设备清空已保存的账号密码，
+	// 因此没有密码时不发送User，保留设备上的账号
+	if data.User.UserName != "" && data.User.Password != "" {
+		result.User = &setUserCredential{
+			UserName: data.User.UserName,
+			Password: data.User.Password,
+		}
+	}
+	return result, nil
+}
+
+type StorageConfigurationsRequest struct {
+	XMLName string `xml:"tds:GetStorageConfigurations"`
+}
+
+type StorageConfigurationsResponse struct {
+	XMLName               string                 `xml:"Envelope"`
+	StorageConfigurations []StorageConfiguration `xml:"Body>GetStorageConfigurationsResponse>StorageConfigurations"`
+}
+
+type CreateStorageConfigurationRequest struct {
+	XMLName              string                      `xml:"tds:CreateStorageConfiguration"`
+	StorageConfiguration setStorageConfigurationData `xml:"tds:StorageConfiguration"`
+}
+
+type CreateStorageConfigurationResponse struct {
+	XMLName string `xml:"Envelope"`
+	Token   string `xml:"Body>CreateStorageConfigurationResponse>Token"`
+}
+
+type SetStorageConfigurationRequest struct {
+	XMLName              string                  `xml:"tds:SetStorageConfiguration"`
+	StorageConfiguration setStorageConfiguration `xml:"tds:StorageConfiguration"`
+}
+
+type setStorageConfiguration struct {
+	Token string                      `xml:"token,attr"`
+	Data  setStorageConfigurationData `xml:"tt:Data"`
+}
+
+type DeleteStorageConfigurationRequest struct {
+	XMLName string `xml:"tds:DeleteStorageConfiguration"`
+	Token   string `xml:"tds:Token"`
+}
+
+type StorageEmptyResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+// 获取SD卡、NAS等存储配置
+func (device *OnvifDevice) GetStorageConfigurations() ([]StorageConfiguration, error) {
+	var request StorageConfigurationsRequest
+	response := &StorageConfigurationsResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetStorageConfigurations", request, response)
+	if err != nil {
+		log.Println("GetStorageConfigurations fail", err)
+		return nil, err
+	}
+
+	return response.StorageConfigurations, nil
+}
+
+// 创建存储配置，返回新配置的令牌
+func (device *OnvifDevice) CreateStorageConfiguration(data StorageConfigurationData) (string, error) {
+	setData, err := data.toSet()
+	if err != nil {
+		return "", err
+	}
+
+	request := CreateStorageConfigurationRequest{StorageConfiguration: setData}
+	response := &CreateStorageConfigurationResponse{}
+	err = device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/CreateStorageConfiguration", request, response)
+	if err != nil {
+		log.Println("CreateStorageConfiguration fail", err)
+		return "", err
+	}
+
+	return response.Token, nil
+}
+
+// 修改存储配置，Data.User.Password为空时不修改设备上保存的网络存储账号
+func (device *OnvifDevice) SetStorageConfiguration(configuration StorageConfiguration) error {
+	if configuration.Token == "" {
+		return errors.New("storage configuration token is empty")
+	}
+
+	setData, err := configuration.Data.toSet()
+	if err != nil {
+		return err
+	}
+
+	request := SetStorageConfigurationRequest{
+		StorageConfiguration: setStorageConfiguration{Token: configuration.Token, Data: setData},
+	}
+	err = device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/SetStorageConfiguration", request, &StorageEmptyResponse{})
+	if err != nil {
+		log.Println("SetStorageConfiguration fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 删除存储配置
+func (device *OnvifDevice) DeleteStorageConfiguration(token string) error {
+	if token == "" {
+		return errors.New("storage configuration token is empty")
+	}
+
+	request := DeleteStorageConfigurationRequest{Token: token}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/DeleteStorageConfiguration", request, &StorageEmptyResponse{})
+	if err != nil {
+		log.Println("DeleteStorageConfiguration fail", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,280 @@
+package device
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// 时间设置方式
+const (
+	DateTimeManual = "Manual"
+	DateTimeNTP    = "NTP"
+)
+
+// 网络主机类型
+const (
+	NetworkHostIPv4 = "IPv4"
+	NetworkHostIPv6 = "IPv6"
+	NetworkHostDNS  = "DNS"
+)
+
+type SystemDateAndTimeRequest struct {
+	XMLName string `xml:"tds:GetSystemDateAndTime"`
+}
+
+type SystemDateAndTimeResponse struct {
+	XMLName           string              `xml:"Envelope"`
+	SystemDateAndTime onvifSystemDateTime `xml:"Body>GetSystemDateAndTimeResponse>SystemDateAndTime"`
+}
+
+type onvifSystemDateTime struct {
+	DateTimeType    string        `xml:"DateTimeType"`
+	DaylightSavings bool          `xml:"DaylightSavings"`
+	TZ              string        `xml:"TimeZone>TZ"`
+	UTCDateTime     onvifDateTime `xml:"UTCDateTime"`
+	LocalDateTime   onvifDateTime `xml:"LocalDateTime"`
+}
+
+type onvifDateTime struct {
+	Hour   int `xml:"Time>Hour"`
+	Minute int `xml:"Time>Minute"`
+	Second int `xml:"Time>Second"`
+	Year   int `xml:"Date>Year"`
+	Month  int `xml:"Date>Month"`
+	Day    int `xml:"Date>Day"`
+}
+
+func (dt onvifDateTime) time(loc *time.Location) time.Time {
+	if dt.Year == 0 {
+		return time.Time{}
+	}
+	return time.Date(dt.Year, time.Month(dt.Month), dt.Day, dt.Hour, dt.Minute, dt.Second, 0, loc)
+}
+
+// 设备系统时间，TimeZone为POSIX TZ格式，例如"CST-8"
+// LocalDateTime的时区无法由POSIX TZ可靠换算，按UTC保存设备上报的时分秒
+type SystemDateAndTime struct {
+	DateTimeType    string
+	DaylightSavings bool
+	TimeZone        string
+	UTCDateTime     time.Time
+	LocalDateTime   time.Time
+}
+
+type SetSystemDateAndTimeRequest struct {
+	XMLName         string                `xml:"tds:SetSystemDateAndTime"`
+	DateTimeType    string                `xml:"tds:DateTimeType"`
+	DaylightSavings bool                  `xml:"tds:DaylightSavings"`
+	TimeZone        *setTimeZone          `xml:"tds:TimeZone,omitempty"`
+	UTCDateTime     *setSystemDateTimeUTC `xml:"tds:UTCDateTime,omitempty"`
+}
+
+// TimeZone中TZ为必填项，不设置时区时整个TimeZone都不能出现
+type setTimeZone struct {
+	TZ string `xml:"tt:TZ"`
+}
+
+type setSystemDateTimeUTC struct {
+	Hour   int `xml:"tt:Time>tt:Hour"`
+	Minute int `xml:"tt:Time>tt:Minute"`
+	Second int `xml:"tt:Time>tt:Second"`
+	Year   int `xml:"tt:Date>tt:Year"`
+	Month  int `xml:"tt:Date>tt:Month"`
+	Day    int `xml:"tt:Date>tt:Day"`
+}
+
+type SetSystemDateAndTimeResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+// NTP服务器地址
+type NetworkHost struct {
+	Type        string `xml:"Type"`
+	IPv4Address string `xml:"IPv4Address"`
+	IPv6Address string `xml:"IPv6Address"`
+	DNSname     string `xml:"DNSname"`
+}
+
+type NTPRequest struct {
+	XMLName string `xml:"tds:GetNTP"`
+}
+
+type NTPResponse struct {
+	XMLName        string         `xml:"Envelope"`
+	NTPInformation NTPInformation `xml:"Body>GetNTPResponse>NTPInformation"`
+}
+
+type NTPInformation struct {
+	FromDHCP    bool          `xml:"FromDHCP"`
+	NTPFromDHCP []NetworkHost `xml:"NTPFromDHCP"`
+	NTPManual   []NetworkHost `xml:"NTPManual"`
+}
+
+type SetNTPRequest struct {
+	XMLName   string           `xml:"tds:SetNTP"`
+	FromDHCP  bool             `xml:"tds:FromDHCP"`
+	NTPManual []setNetworkHost `xml:"tds:NTPManual"`
+}
+
+type setNetworkHost struct {
+	Type        string `xml:"tt:Type"`
+	IPv4Address string `xml:"tt:IPv4Address,omitempty"`
+	IPv6Address string `xml:"tt:IPv6Address,omitempty"`
+	DNSname     string `xml:"tt:DNSname,omitempty"`
+}
+
+type SetNTPResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+func toSetNetworkHosts(hosts []NetworkHost) []setNetworkHost {
+	var result []setNetworkHost
+	for _, host := range hosts {
+		result = append(result, setNetworkHost{
+			Type:        host.Type,
+			IPv4Address: host.IPv4Address,
+			IPv6Address: host.IPv6Address,
+			DNSname:     host.DNSname,
+		})
+	}
+	return result
+}
+
+// 获取设备时间设置及当前时间，并更新ClockOffset。
+// 该接口不需要认证，不带WS-Security令牌请求，时钟偏差过大的设备也能正常应答
+func (device *OnvifDevice) GetSystemDateAndTime() (*SystemDateAndTime, error) {
+	anonymous := *device
+	anonymous.User, anonymous.Passwd = "", ""
+
+	var request SystemDateAndTimeRequest
+	response := &SystemDateAndTimeResponse{}
+	err := anonymous.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetSystemDateAndTime", request, response)
+	if err != nil {
+		log.Println("GetSystemDateAndTime fail", err)
+		return nil, err
+	}
+
+	raw := response.SystemDateAndTime
+	dateTime := &SystemDateAndTime{
+		DateTimeType:    raw.DateTimeType,
+		DaylightSavings: raw.DaylightSavings,
+		TimeZone:        raw.TZ,
+		UTCDateTime:     raw.UTCDateTime.time(time.UTC),
+		LocalDateTime:   raw.LocalDateTime.time(time.UTC),
+	}
+	if !dateTime.UTCDateTime.IsZero() {
+		device.ClockOffset = dateTime.UTCDateTime.Sub(time.Now().UTC())
+	}
+	return dateTime, nil
+}
+
+// 设置设备时间。NTP方式下忽略UTCDateTime；Manual方式下UTCDateTime为零值时只修改时区和夏令时，
+// TimeZone为空时不修改时区。发送前先读取设备时间，认证令牌按设备时钟生成
+func (device *OnvifDevice) SetSystemDateAndTime(dateTime SystemDateAndTime) error {
+	if dateTime.DateTimeType != DateTimeManual && dateTime.DateTimeType != DateTimeNTP {
+		return errors.New("DateTimeType must be Manual or NTP")
+	}
+
+	if _, err := device.GetSystemDateAndTime(); err != nil {
+		return err
+	}
+	return device.setSystemDateAndTime(dateTime)
+}
+
+func (device *OnvifDevice) setSystemDateAndTime(dateTime SystemDateAndTime) error {
+	request := SetSystemDateAndTimeRequest{
+		DateTimeType:    dateTime.DateTimeType,
+		DaylightSavings: dateTime.DaylightSavings,
+	}
+	if dateTime.TimeZone != "" {
+		request.TimeZone = &setTimeZone{TZ: dateTime.TimeZone}
+	}
+	if dateTime.DateTimeType == DateTimeManual && !dateTime.UTCDateTime.IsZero() {
+		utc := dateTime.UTCDateTime.UTC()
+		request.UTCDateTime = &setSystemDateTimeUTC{
+			Hour:   utc.Hour(),
+			Minute: utc.Minute(),
+			Second: utc.Second(),
+			Year:   utc.Year(),
+			Month:  int(utc.Month()),
+			Day:    utc.Day(),
+		}
+	}
+
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/SetSystemDateAndTime", request, &SetSystemDateAndTimeResponse{})
+	if err != nil {
+		log.Println("SetSystemDateAndTime fail", err)
+		return err
+	}
+
+	// 手动校时后设备时钟已知，后续请求的认证令牌按新时间生成
+	if request.UTCDateTime != nil {
+		device.ClockOffset = dateTime.UTCDateTime.Sub(time.Now())
+	}
+	return nil
+}
+
+// 设备时钟与本机时钟的偏差，正值表示设备时间超前
+func (device *OnvifDevice) GetClockOffset() (time.Duration, error) {
+	dateTime, err := device.GetSystemDateAndTime()
+	if err != nil {
+		return 0, err
+	}
+	if dateTime.UTCDateTime.IsZero() {
+		return 0, errors.New("device did not report UTCDateTime")
+	}
+
+	return device.ClockOffset, nil
+}
+
+// 将设备切换为手动校时并同步为本机当前时间，保留设备原有时区和夏令时设置
+func (device *OnvifDevice) SyncSystemDateAndTime() error {
+	current, err := device.GetSystemDateAndTime()
+	if err != nil {
+		return err
+	}
+
+	return device.setSystemDateAndTime(SystemDateAndTime{
+		DateTimeType:    DateTimeManual,
+		DaylightSavings: current.DaylightSavings,
+		TimeZone:        current.TimeZone,
+		UTCDateTime:     time.Now().UTC(),
+	})
+}
+
+// 获取NTP服务器设置
+func (device *OnvifDevice) GetNTP() (*NTPInformation, error) {
+	var request NTPRequest
+	response := &NTPResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetNTP", request, response)
+	if err != nil {
+		log.Println("GetNTP fail", err)
+		return nil, err
+	}
+
+	return &response.NTPInformation, nil
+}
+
+// 设置NTP服务器，fromDHCP为true时通过DHCP获取，servers被忽略
+func (device *OnvifDevice) SetNTP(fromDHCP bool, servers []NetworkHost) error {
+	request := SetNTPRequest{FromDHCP: fromDHCP}
+	if !fromDHCP {
+		if len(servers) == 0 {
+			return errors.New("manual NTP requires at least one server")
+		}
+		request.NTPManual = toSetNetworkHosts(servers)
+	}
+
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/SetNTP", request, &SetNTPResponse{})
+	if err != nil {
+		log.Println("SetNTP fail", err)
+		return err
+	}
+
+	return nil
+}
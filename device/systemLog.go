@@ -0,0 +1,150 @@
+package device
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"strings"
+)
+
+// 日志类型
+const (
+	SystemLogTypeSystem = "System"
+	SystemLogTypeAccess = "Access"
+)
+
+// 二进制数据，可能是xop:Include引用的MTOM附件，也可能是内联的base64文本
+type AttachmentData struct {
+	ContentType string     `xml:"contentType,attr"`
+	Include     xopInclude `xml:"Include"`
+	Inline      string     `xml:",chardata"`
+}
+
+type xopInclude struct {
+	Href string `xml:"href,attr"`
+}
+
+type setAttachmentData struct {
+	ContentType string        `xml:"xmime:contentType,attr,omitempty"`
+	Include     setXopInclude `xml:"xop:Include"`
+}
+
+type setXopInclude struct {
+	Href string `xml:"href,attr"`
+}
+
+// 取出二进制内容，attachments为MTOM应答中的附件
+func (data *AttachmentData) bytes(attachments map[string][]byte) ([]byte, error) {
+	if href := data.Include.Href; href != "" {
+		id := strings.TrimPrefix(href, "cid:")
+		if unescaped, err := url.PathUnescape(id); err == nil {
+			id = unescaped
+		}
+		content, ok := attachments[id]
+		if !ok {
+			return nil, fmt.Errorf("attachment %s not found in response", href)
+		}
+		return content, nil
+	}
+
+	inline := strings.TrimSpace(data.Inline)
+	if inline == "" {
+		return nil, nil
+	}
+	return base64.StdEncoding.DecodeString(inline)
+}
+
+type attachedText struct {
+	Binary *AttachmentData `xml:"Binary"`
+	String string          `xml:"String"`
+}
+
+// 设备日志或支持信息，Binary和String通常只有一个有内容
+type SystemLog struct {
+	ContentType string
+	Binary      []byte
+	String      string
+}
+
+// 支持信息与日志的结构相同
+type SupportInformation = SystemLog
+
+func (text *attachedText) systemLog(attachments map[string][]byte) (*SystemLog, error) {
+	result := &SystemLog{String: text.String}
+	if text.Binary != nil {
+		content, err := text.Binary.bytes(attachments)
+		if err != nil {
+			return nil, err
+		}
+		result.Binary = content
+		result.ContentType = text.Binary.ContentType
+	}
+	return result, nil
+}
+
+// 日志内容，优先返回二进制附件
+func (systemLog *SystemLog) Bytes() []byte {
+	if len(systemLog.Binary) > 0 {
+		return systemLog.Binary
+	}
+	return []byte(systemLog.String)
+}
+
+// 将日志内容写入文件
+func (systemLog *SystemLog) WriteToFile(path string) error {
+	return ioutil.WriteFile(path, systemLog.Bytes(), 0644)
+}
+
+type SystemLogRequest struct {
+	XMLName string `xml:"tds:GetSystemLog"`
+	LogType string `xml:"tds:LogType"`
+}
+
+type SystemLogResponse struct {
+	XMLName   string       `xml:"Envelope"`
+	SystemLog attachedText `xml:"Body>GetSystemLogResponse>SystemLog"`
+}
+
+type SystemSupportInformationRequest struct {
+	XMLName string `xml:"tds:GetSystemSupportInformation"`
+}
+
+type SystemSupportInformationResponse struct {
+	XMLName            string       `xml:"Envelope"`
+	SupportInformation attachedText `xml:"Body>GetSystemSupportInformationResponse>SupportInformation"`
+}
+
+// 获取设备日志，logType取值System或Access
+func (device *OnvifDevice) GetSystemLog(logType string) (*SystemLog, error) {
+	if logType != SystemLogTypeSystem && logType != SystemLogTypeAccess {
+		return nil, errors.New("log type must be System or Access")
+	}
+
+	request := SystemLogRequest{LogType: logType}
+	response := &SystemLogResponse{}
+	attachments, err := device.callMethodWithAttachments(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetSystemLog", request, response)
+	if err != nil {
+		log.Println("GetSystemLog fail", err)
+		return nil, err
+	}
+
+	return response.SystemLog.systemLog(attachments)
+}
+
+// 获取设备支持信息
+func (device *OnvifDevice) GetSystemSupportInformation() (*SupportInformation, error) {
+	var request SystemSupportInformationRequest
+	response := &SystemSupportInformationResponse{}
+	attachments, err := device.callMethodWithAttachments(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetSystemSupportInformation", request, response)
+	if err != nil {
+		log.Println("GetSystemSupportInformation fail", err)
+		return nil, err
+	}
+
+	return response.SupportInformation.systemLog(attachments)
+}
@@ -0,0 +1,205 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// 用户级别
+const (
+	UserLevelAdministrator = "Administrator"
+	UserLevelOperator      = "Operator"
+	UserLevelUser          = "User"
+	UserLevelAnonymous     = "Anonymous"
+	UserLevelExtended      = "Extended"
+)
+
+// 设备用户，GetUsers不返回Password
+type User struct {
+	Username  string `xml:"Username"`
+	Password  string `xml:"Password"`
+	UserLevel string `xml:"UserLevel"`
+}
+
+type setUser struct {
+	Username  string `xml:"tt:Username"`
+	Password  string `xml:"tt:Password,omitempty"`
+	UserLevel string `xml:"tt:UserLevel"`
+}
+
+func toSetUsers(users []User) ([]setUser, error) {
+	var result []setUser
+	for _, user := range users {
+		if user.Username == "" {
+			return nil, errors.New("username is empty")
+		}
+		switch user.UserLevel {
+		case UserLevelAdministrator, UserLevelOperator, UserLevelUser, UserLevelAnonymous, UserLevelExtended:
+		default:
+			return nil, fmt.Errorf("invalid user level %q for %s", user.UserLevel, user.Username)
+		}
+		result = append(result, setUser{
+			Username:  user.Username,
+			Password:  user.Password,
+			UserLevel: user.UserLevel,
+		})
+	}
+	return result, nil
+}
+
+type UsersRequest struct {
+	XMLName string `xml:"tds:GetUsers"`
+}
+
+type UsersResponse struct {
+	XMLName string `xml:"Envelope"`
+	User    []User `xml:"Body>GetUsersResponse>User"`
+}
+
+type CreateUsersRequest struct {
+	XMLName string    `xml:"tds:CreateUsers"`
+	User    []setUser `xml:"tds:User"`
+}
+
+type CreateUsersResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+type DeleteUsersRequest struct {
+	XMLName  string   `xml:"tds:DeleteUsers"`
+	Username []string `xml:"tds:Username"`
+}
+
+type DeleteUsersResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+type SetUserRequest struct {
+	XMLName string    `xml:"tds:SetUser"`
+	User    []setUser `xml:"tds:User"`
+}
+
+type SetUserResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+// 获取设备用户列表
+func (device *OnvifDevice) GetUsers() ([]User, error) {
+	var request UsersRequest
+	response := &UsersResponse{}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/GetUsers", request, response)
+	if err != nil {
+		log.Println("GetUsers fail", err)
+		return nil, err
+	}
+
+	return response.User, nil
+}
+
+// 创建用户
+func (device *OnvifDevice) CreateUsers(users []User) error {
+	if len(users) == 0 {
+		return errors.New("no user to create")
+	}
+
+	setUsers, err := toSetUsers(users)
+	if err != nil {
+		return err
+	}
+
+	request := CreateUsersRequest{User: setUsers}
+	err = device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/CreateUsers", request, &CreateUsersResponse{})
+	if err != nil {
+		log.Println("CreateUsers fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 删除用户
+func (device *OnvifDevice) DeleteUsers(usernames []string) error {
+	if len(usernames) == 0 {
+		return errors.New("no user to delete")
+	}
+
+	request := DeleteUsersRequest{Username: usernames}
+	err := device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/DeleteUsers", request, &DeleteUsersResponse{})
+	if err != nil {
+		log.Println("DeleteUsers fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 修改已有用户的密码或级别
+func (device *OnvifDevice) SetUser(users []User) error {
+	if len(users) == 0 {
+		return errors.New("no user to set")
+	}
+
+	setUsers, err := toSetUsers(users)
+	if err != nil {
+		return err
+	}
+
+	request := SetUserRequest{User: setUsers}
+	err = device.callMethod(device.deviceServiceAddr(),
+		"http://www.onvif.org/ver10/device/wsdl/SetUser", request, &SetUserResponse{})
+	if err != nil {
+		log.Println("SetUser fail", err)
+		return err
+	}
+
+	return nil
+}
+
+// 修改当前登录用户的密码。新密码在设备上验证通过后才更新device.Passwd，
+// 验证失败时device仍使用旧密码，返回的错误说明旧密码是否仍然有效
+func (device *OnvifDevice) RotatePassword(newPasswd string) error {
+	if newPasswd == "" {
+		return errors.New("new password is empty")
+	}
+
+	users, err := device.GetUsers()
+	if err != nil {
+		return err
+	}
+
+	var current *User
+	for i := range users {
+		if users[i].Username == device.User {
+			current = &users[i]
+			break
+		}
+	}
+	if current == nil {
+		return fmt.Errorf("user %s not found on device", device.User)
+	}
+
+	err = device.SetUser([]User{{
+		Username:  current.Username,
+		Password:  newPasswd,
+		UserLevel: current.UserLevel,
+	}})
+	if err != nil {
+		return err
+	}
+
+	verify := *device
+	verify.Passwd = newPasswd
+	if _, err := verify.GetUsers(); err != nil {
+		if _, oldErr := device.GetUsers(); oldErr == nil {
+			return fmt.Errorf("new password rejected, old password still valid: %v", err)
+		}
+		return fmt.Errorf("new password rejected and old password no longer valid: %v", err)
+	}
+
+	device.Passwd = newPasswd
+	return nil
+}
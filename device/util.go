@@ -2,7 +2,12 @@ package device
 
 import (
 	"encoding/xml"
+	"errors"
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/beevik/etree"
 )
@@ -15,6 +20,10 @@ type OnvifDevice struct {
 	Profile      *ProfileResponse
 	StreamUri    *StreamUriResponse
 	Capabilities *CapbilityResponse
+	Services     []Service
+
+	// 设备时钟与本机时钟的偏差，由GetSystemDateAndTime更新，WS-Security令牌按设备时间生成
+	ClockOffset time.Duration
 }
 
 func buildElement(method interface{}) (*etree.Element, error) {
@@ -31,3 +40,157 @@ func buildElement(method interface{}) (*etree.Element, error) {
 	element := doc.Root()
 	return element, nil
 }
+
+// 设备管理服务地址，未获取能力集时使用默认地址
+func (device *OnvifDevice) deviceServiceAddr() string {
+	if device.Capabilities != nil && device.Capabilities.Capabilities.Device.XAddr != "" {
+		return device.Capabilities.Capabilities.Device.XAddr
+	}
+	return "http://" + device.DeviceIp + "/onvif/device_service"
+}
+
+// 确保已获取能力集
+func (device *OnvifDevice) ensureCapabilities() error {
+	if device.Capabilities == nil {
+		if _, err := device.GetCapabilities(); err != nil {
+			log.Println("device.GetCapabilities fail", err)
+			return fmt.Errorf("device.GetCapabilities fail: %w", err)
+		}
+	}
+	return nil
+}
+
+// 清空缓存的能力集、服务列表、媒体文件和流地址，设备地址或配置变化后需要重新获取
+func (device *OnvifDevice) invalidateCache() {
+	device.Capabilities = nil
+	device.Services = nil
+	device.Profile = nil
+	device.StreamUri = nil
+}
+
+// 解析xs:duration格式的时长，例如"PT1M30S"、"PT0.5S"，不支持年和月
+func parseDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	rest := value
+	negative := strings.HasPrefix(rest, "-")
+	rest = strings.TrimPrefix(rest, "-")
+	if !strings.HasPrefix(rest, "P") {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	rest = rest[1:]
+
+	var total time.Duration
+	inTime := false
+	components, timeComponents := 0, 0
+	for rest != "" {
+		if rest[0] == 'T' {
+			if inTime {
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+			inTime = true
+			rest = rest[1:]
+			continue
+		}
+
+		i := strings.IndexAny(rest, "YMWDHS")
+		if i <= 0 || strings.Trim(rest[:i], "0123456789.") != "" {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		number, err := strconv.ParseFloat(rest[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+
+		var unit time.Duration
+		switch {
+		case rest[i] == 'W' && !inTime:
+			unit = 7 * 24 * time.Hour
+		case rest[i] == 'D' && !inTime:
+			unit = 24 * time.Hour
+		case rest[i] == 'H' && inTime:
+			unit = time.Hour
+		case rest[i] == 'M' && inTime:
+			unit = time.Minute
+		case rest[i] == 'S' && inTime:
+			unit = time.Second
+		default:
+			return 0, fmt.Errorf("unsupported duration %q", value)
+		}
+		total += time.Duration(number * float64(unit))
+		rest = rest[i+1:]
+		components++
+		if inTime {
+			timeComponents++
+		}
+	}
+
+	// "P"、"PT"、"P1DT"这类没有数值或T后没有时间部分的写法不合法
+	if components == 0 || (inTime && timeComponents == 0) {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	if negative {
+		total = -total
+	}
+	return total, nil
+}
+
+// 将时长格式化为xs:duration，例如"PT1.5S"
+func formatDuration(d time.Duration) string {
+	return "PT" + strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S"
+}
+
+// 媒体服务地址
+func (device *OnvifDevice) mediaServiceAddr() (string, error) {
+	if err := device.ensureCapabilities(); err != nil {
+		return "", err
+	}
+	addr := device.Capabilities.Capabilities.Media.XAddr
+	if addr == "" {
+		return "", errors.New("the device do not support media")
+	}
+	return addr, nil
+}
+
+// 云台服务地址
+func (device *OnvifDevice) ptzServiceAddr() (string, error) {
+	if err := device.ensureCapabilities(); err != nil {
+		return "", err
+	}
+	addr := device.Capabilities.Capabilities.PTZ.XAddr
+	if addr == "" {
+		return "", errors.New("the device do not support ptz")
+	}
+	return addr, nil
+}
+
+// 确保已获取媒体文件
+func (device *OnvifDevice) ensureProfiles() error {
+	if device.Profile == nil {
+		if _, err := device.GetProfiles(); err != nil {
+			log.Println("device.GetProfiles fail")
+			return errors.New("get profile fail")
+		}
+	}
+	if len(device.Profile.Profile) == 0 {
+		return errors.New("the device has no profile")
+	}
+	return nil
+}
+
+// 按令牌查找已缓存的媒体文件
+func (device *OnvifDevice) findProfile(profileToken string) (*Profile, error) {
+	if err := device.ensureProfiles(); err != nil {
+		return nil, err
+	}
+	for i := range device.Profile.Profile {
+		if device.Profile.Profile[i].Token == profileToken {
+			return &device.Profile.Profile[i], nil
+		}
+	}
+	return nil, fmt.Errorf("profile %s not found", profileToken)
+}
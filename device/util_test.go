@@ -0,0 +1,82 @@
+package device
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"", 0, true},
+		{"PT0S", 0, true},
+		{"PT30S", 30 * time.Second, true},
+		{"PT0.5S", 500 * time.Millisecond, true},
+		{"PT1M30S", 90 * time.Second, true},
+		{"PT2H", 2 * time.Hour, true},
+		{"P1D", 24 * time.Hour, true},
+		{"P1W", 7 * 24 * time.Hour, true},
+		{"P1DT1H", 25 * time.Hour, true},
+		{" PT10S\n", 10 * time.Second, true},
+		{"-PT5S", -5 * time.Second, true},
+		{"P", 0, false},
+		{"PT", 0, false},
+		{"P1DT", 0, false},
+		{"-P", 0, false},
+		{"T1S", 0, false},
+		{"30", 0, false},
+		{"PT1ST1S", 0, false},
+		{"PTS", 0, false},
+		{"PT1.2.3S", 0, false},
+		{"PT-1S", 0, false},
+		{"PTNaNS", 0, false},
+		{"PT1X", 0, false},
+		{"P1H", 0, false},
+		{"PT1D", 0, false},
+		{"P1Y", 0, false},
+		{"P1M", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			d, err := parseDuration(test.value)
+			if !test.ok {
+				if err == nil {
+					t.Fatalf("parseDuration(%q) = %v, want error", test.value, d)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d != test.expected {
+				t.Fatalf("parseDuration(%q) = %v, want %v", test.value, d, test.expected)
+			}
+		})
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d        time.Duration
+		expected string
+	}{
+		{0, "PT0S"},
+		{30 * time.Second, "PT30S"},
+		{1500 * time.Millisecond, "PT1.5S"},
+		{2 * time.Hour, "PT7200S"},
+	}
+
+	for _, test := range tests {
+		value := formatDuration(test.d)
+		if value != test.expected {
+			t.Fatalf("formatDuration(%v) = %q, want %q", test.d, value, test.expected)
+		}
+		if d, err := parseDuration(value); err != nil || d != test.d {
+			t.Fatalf("parseDuration(%q) = %v, %v, want %v", value, d, err, test.d)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// 视频编码格式
+const (
+	VideoEncodingJPEG  = "JPEG"
+	VideoEncodingMPEG4 = "MPEG4"
+	VideoEncodingH264  = "H264"
+)
+
+func (resolution Resolution) String() string {
+	return fmt.Sprintf("%dx%d", resolution.Width, resolution.Height)
+}
+
+// 视频编码配置可选参数，设备不支持的编码格式对应的ResolutionsAvailable为空
+type VideoEncoderConfigurationOptions struct {
+	QualityRange IntRange            `xml:"QualityRange"`
+	JPEG         VideoEncoderOptions `xml:"JPEG"`
+	MPEG4        VideoEncoderOptions `xml:"MPEG4"`
+	H264         VideoEncoderOptions `xml:"H264"`
+}
+
+// 某种编码格式的可选参数，BitrateRange来自Extension，设备未提供时为零值
+type VideoEncoderOptions struct {
+	ResolutionsAvailable   []Resolution `xml:"ResolutionsAvailable"`
+	GovLengthRange         IntRange     `xml:"GovLengthRange"`
+	FrameRateRange         IntRange     `xml:"FrameRateRange"`
+	EncodingIntervalRange  IntRange     `xml:"EncodingIntervalRange"`
+	Mpeg4ProfilesSupported []string     `xml:"Mpeg4ProfilesSupported"`
+	H264ProfilesSupported  []string     `xml:"H264ProfilesSupported"`
+	BitrateRange           IntRange     `xml:"-"`
+}
+
+type videoEncoderConfigurationOptions struct {
+	VideoEncoderConfigurationOptions
+	JPEGBitrateRange  IntRange `xml:"Extension>JPEG>BitrateRange"`
+	MPEG4BitrateRange IntRange `xml:"Extension>MPEG4>BitrateRange"`
+	H264BitrateRange  IntRange `xml:"Extension>H264>BitrateRange"`
+}
+
+// 按编码格式取可选参数
+func (options *VideoEncoderConfigurationOptions) Encoder(encoding string) (*VideoEncoderOptions, error) {
+	var encoder *VideoEncoderOptions
+	switch encoding {
+	case VideoEncodingJPEG:
+		encoder = &options.JPEG
+	case VideoEncodingMPEG4:
+		encoder = &options.MPEG4
+	case VideoEncodingH264:
+		encoder = &options.H264
+	}
+
+	if encoder == nil || len(encoder.ResolutionsAvailable) == 0 {
+		return nil, fmt.Errorf("encoding %q not supported (allowed: %s)",
+			encoding, strings.Join(options.Encodings(), ", "))
+	}
+	return encoder, nil
+}
+
+// 设备支持的编码格式
+func (options *VideoEncoderConfigurationOptions) Encodings() []string {
+	var encodings []string
+	if len(options.JPEG.ResolutionsAvailable) > 0 {
+		encodings = append(encodings, VideoEncodingJPEG)
+	}
+	if len(options.MPEG4.ResolutionsAvailable) > 0 {
+		encodings = append(encodings, VideoEncodingMPEG4)
+	}
+	if len(options.H264.ResolutionsAvailable) > 0 {
+		encodings = append(encodings, VideoEncodingH264)
+	}
+	return encodings
+}
+
+// 检查编码配置是否在设备声明的范围内，所有不合法的参数及其允许值汇总在返回的错误中。
+// 设备未声明的范围(Max为0)不检查
+func (options *VideoEncoderConfigurationOptions) Validate(configuration VideoEncoderConfiguration) error {
+	encoder, err := options.Encoder(configuration.Encoding)
+	if err != nil {
+		return fmt.Errorf("invalid video encoder configuration: %v", err)
+	}
+
+	var failures []string
+	fail := func(format string, args ...interface{}) {
+		failures = append(failures, fmt.Sprintf(format, args...))
+	}
+	checkRange := func(name string, value int, r IntRange) {
+		if r.Max > 0 && !r.Contains(value) {
+			fail("%s %d out of range %s", name, value, r)
+		}
+	}
+	checkList := func(name, value string, allowed []string) {
+		if len(allowed) == 0 {
+			return
+		}
+		for _, item := range allowed {
+			if item == value {
+				return
+			}
+		}
+		fail("%s %q not supported (allowed: %s)", name, value, strings.Join(allowed, ", "))
+	}
+
+	found := false
+	var resolutions []string
+	for _, resolution := range encoder.ResolutionsAvailable {
+		found = found || resolution == configuration.Resolution
+		resolutions = append(resolutions, resolution.String())
+	}
+	if !found {
+		fail("resolution %s not supported by %s (allowed: %s)",
+			configuration.Resolution, configuration.Encoding, strings.Join(resolutions, ", "))
+	}
+
+	quality, err := strconv.ParseFloat(configuration.Quality, 64)
+	if err != nil {
+		fail("invalid quality %q", configuration.Quality)
+	} else if options.QualityRange.Max > 0 &&
+		(quality < float64(options.QualityRange.Min) || quality > float64(options.QualityRange.Max)) {
+		fail("quality %s out of range %s", configuration.Quality, options.QualityRange)
+	}
+
+	// 未设置码率控制时不发送RateControl，也不检查
+	if rateControl := configuration.RateControl; rateControl != (RateControl{}) {
+		checkRange("frame rate", rateControl.FrameRateLimit, encoder.FrameRateRange)
+		checkRange("encoding interval", rateControl.EncodingInterval, encoder.EncodingIntervalRange)
+		checkRange("bitrate", rateControl.BitrateLimit, encoder.BitrateRange)
+	}
+
+	switch configuration.Encoding {
+	case VideoEncodingMPEG4:
+		checkRange("gov length", configuration.MPEG4.GovLength, encoder.GovLengthRange)
+		checkList("mpeg4 profile", configuration.MPEG4.Mpeg4Profile, encoder.Mpeg4ProfilesSupported)
+	case VideoEncodingH264:
+		checkRange("gov length", configuration.H264.GovLength, encoder.GovLengthRange)
+		checkList("h264 profile", configuration.H264.H264Profile, encoder.H264ProfilesSupported)
+	}
+
+	if len(failures) > 0 {
+		return errors.New("invalid video encoder configuration: " + strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+type setVideoEncoderConfiguration struct {
+	Token          string          `xml:"token,attr"`
+	Name           string          `xml:"tt:Name"`
+	UseCount       int             `xml:"tt:UseCount"`
+	Encoding       string          `xml:"tt:Encoding"`
+	Width          int             `xml:"tt:Resolution>tt:Width"`
+	Height         int             `xml:"tt:Resolution>tt:Height"`
+	Quality        string          `xml:"tt:Quality"`
+	RateControl    *setRateControl `xml:"tt:RateControl,omitempty"`
+	MPEG4          *setMPEG4       `xml:"tt:MPEG4,omitempty"`
+	H264           *setH264        `xml:"tt:H264,omitempty"`
+	Multicast      setMulticast    `xml:"tt:Multicast"`
+	SessionTimeout string          `xml:"tt:SessionTimeout,omitempty"`
+}
+
+type setRateControl struct {
+	FrameRateLimit   int `xml:"tt:FrameRateLimit"`
+	EncodingInterval int `xml:"tt:EncodingInterval"`
+	BitrateLimit     int `xml:"tt:BitrateLimit"`
+}
+
+type setMPEG4 struct {
+	GovLength    int    `xml:"tt:GovLength"`
+	Mpeg4Profile string `xml:"tt:Mpeg4Profile"`
+}
+
+type setH264 struct {
+	GovLength   int    `xml:"tt:GovLength"`
+	H264Profile string `xml:"tt:H264Profile"`
+}
+
+type setMulticast struct {
+	Type        string `xml:"tt:Address>tt:Type"`
+	IPv4Address string `xml:"tt:Address>tt:IPv4Address,omitempty"`
+	IPv6Address string `xml:"tt:Address>tt:IPv6Address,omitempty"`
+	Port        int    `xml:"tt:Port"`
+	TTL         int    `xml:"tt:TTL"`
+	AutoStart   bool   `xml:"tt:AutoStart"`
+}
+
+// 组播配置为必填项，设备未返回组播地址时使用0.0.0.0
+func (multicast *Multicast) toSet() setMulticast {
+	address := multicast.IPAddress
+	if address == (Address{}) && multicast.Address != "" {
+		if ip := net.ParseIP(multicast.Address); ip != nil && ip.To4() == nil {
+			address = Address{Type: NetworkHostIPv6, IPv6Address: multicast.Address}
+		} else {
+			address = Address{Type: NetworkHostIPv4, IPv4Address: multicast.Address}
+		}
+	}
+
+	result := setMulticast{
+		Type:        address.Type,
+		IPv4Address: address.IPv4Address,
+		IPv6Address: address.IPv6Address,
+		Port:        multicast.Port,
+		TTL:         multicast.TTL,
+		AutoStart:   multicast.AutoStart,
+	}
+	if result.Type == "" {
+		result.Type = NetworkHostIPv4
+	}
+	if result.Type == NetworkHostIPv4 && result.IPv4Address == "" {
+		result.IPv4Address = "0.0.0.0"
+	}
+	return result
+}
+
+func (configuration *VideoEncoderConfiguration) toSet() setVideoEncoderConfiguration {
+	result := setVideoEncoderConfiguration{
+		Token:          configuration.Token,
+		Name:           configuration.Name,
+		UseCount:       configuration.UseCount,
+		Encoding:       configuration.Encoding,
+		Width:          configuration.Resolution.Width,
+		Height:         configuration.Resolution.Height,
+		Quality:        configuration.Quality,
+		Multicast:      configuration.Multicast.toSet(),
+		SessionTimeout: configuration.SessionTimeout,
+	}
+	if configuration.RateControl != (RateControl{}) {
+		result.RateControl = &setRateControl{
+			FrameRateLimit:   configuration.RateControl.FrameRateLimit,
+			EncodingInterval: configuration.RateControl.EncodingInterval,
+			BitrateLimit:     configuration.RateControl.BitrateLimit,
+		}
+	}
+	switch configuration.Encoding {
+	case VideoEncodingMPEG4:
+		result.MPEG4 = &setMPEG4{
+			GovLength:    configuration.MPEG4.GovLength,
+			Mpeg4Profile: configuration.MPEG4.Mpeg4Profile,
+		}
+	case VideoEncodingH264:
+		result.H264 = &setH264{
+			GovLength:   configuration.H264.GovLength,
+			H264Profile: configuration.H264.H264Profile,
+		}
+	}
+	return result
+}
+
+type VideoEncoderConfigurationsRequest struct {
+	XMLName string `xml:"trt:GetVideoEncoderConfigurations"`
+}
+
+type VideoEncoderConfigurationsResponse struct {
+	XMLName        string                      `xml:"Envelope"`
+	Configurations []VideoEncoderConfiguration `xml:"Body>GetVideoEncoderConfigurationsResponse>Configurations"`
+}
+
+type VideoEncoderConfigurationOptionsRequest struct {
+	XMLName            string `xml:"trt:GetVideoEncoderConfigurationOptions"`
+	ConfigurationToken string `xml:"trt:ConfigurationToken,omitempty"`
+	ProfileToken       string `xml:"trt:ProfileToken,omitempty"`
+}
+
+type VideoEncoderConfigurationOptionsResponse struct {
+	XMLName string                           `xml:"Envelope"`
+	Options videoEncoderConfigurationOptions `xml:"Body>GetVideoEncoderConfigurationOptionsResponse>Options"`
+}
+
+type SetVideoEncoderConfigurationRequest struct {
+	XMLName          string                       `xml:"trt:SetVideoEncoderConfiguration"`
+	Configuration    setVideoEncoderConfiguration `xml:"trt:Configuration"`
+	ForcePersistence bool                         `xml:"trt:ForcePersistence"`
+}
+
+type VideoEncoderEmptyResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+// 获取所有视频编码配置
+func (device *OnvifDevice) GetVideoEncoderConfigurations() ([]VideoEncoderConfiguration, error) {
+	mediaAddr, err := device.mediaServiceAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	var request VideoEncoderConfigurationsRequest
+	response := &VideoEncoderConfigurationsResponse{}
+	err = device.callMethod(mediaAddr,
+		"http://www.onvif.org/ver10/media/wsdl/GetVideoEncoderConfigurations", request, response)
+	if err != nil {
+		log.Println("GetVideoEncoderConfigurations fail", err)
+		return nil, err
+	}
+
+	return response.Configurations, nil
+}
+
+// 获取视频编码配置的可选参数，configurationToken、profileToken为空时不指定
+func (device *OnvifDevice) GetVideoEncoderConfigurationOptions(configurationToken, profileToken string) (*VideoEncoderConfigurationOptions, error) {
+	mediaAddr, err := device.mediaServiceAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	request := VideoEncoderConfigurationOptionsRequest{
+		ConfigurationToken: configurationToken,
+		ProfileToken:       profileToken,
+	}
+	response := &VideoEncoderConfigurationOptionsResponse{}
+	err = device.callMethod(mediaAddr,
+		"http://www.onvif.org/ver10/media/wsdl/GetVideoEncoderConfigurationOptions", request, response)
+	if err != nil {
+		log.Println("GetVideoEncoderConfigurationOptions fail", err)
+		return nil, err
+	}
+
+	options := response.Options.VideoEncoderConfigurationOptions
+	options.JPEG.BitrateRange = response.Options.JPEGBitrateRange
+	options.MPEG4.BitrateRange = response.Options.MPEG4BitrateRange
+	options.H264.BitrateRange = response.Options.H264BitrateRange
+	return &options, nil
+}
+
+// 修改视频编码配置(码率、GOP、分辨率、帧率等)，发送前按设备声明的可选参数检查。
+// forcePersistence为false时设备重启后可能恢复原配置
+func (device *OnvifDevice) SetVideoEncoderConfiguration(configuration VideoEncoderConfiguration, forcePersistence bool) error {
+	if configuration.Token == "" {
+		return errors.New("video encoder configuration token is empty")
+	}
+
+	options, err := device.GetVideoEncoderConfigurationOptions(configuration.Token, "")
+	if err != nil {
+		return err
+	}
+	if err := options.Validate(configuration); err != nil {
+		return err
+	}
+
+	mediaAddr, err := device.mediaServiceAddr()
+	if err != nil {
+		return err
+	}
+
+	request := SetVideoEncoderConfigurationRequest{
+		Configuration:    configuration.toSet(),
+		ForcePersistence: forcePersistence,
+	}
+	err = device.callMethod(mediaAddr,
+		"http://www.onvif.org/ver10/media/wsdl/SetVideoEncoderConfiguration", request, &VideoEncoderEmptyResponse{})
+	if err != nil {
+		log.Println("SetVideoEncoderConfiguration fail", err)
+		return err
+	}
+
+	// 缓存的媒体文件中包含旧配置
+	device.Profile = nil
+	return nil
+}
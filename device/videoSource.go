@@ -0,0 +1,214 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// 视频源，即一路传感器输入。Token同时是成像服务中使用的VideoSourceToken
+type VideoSource struct {
+	Token      string          `xml:"token,attr"`
+	Framerate  float64         `xml:"Framerate"`
+	Resolution Resolution      `xml:"Resolution"`
+	Imaging    ImagingSettings `xml:"Imaging"`
+}
+
+// 视频源当前的成像参数
+type ImagingSettings struct {
+	Brightness      float64 `xml:"Brightness"`
+	ColorSaturation float64 `xml:"ColorSaturation"`
+	Contrast        float64 `xml:"Contrast"`
+	Sharpness       float64 `xml:"Sharpness"`
+	IrCutFilter     string  `xml:"IrCutFilter"`
+}
+
+// 整数取值范围
+type IntRange struct {
+	Min int `xml:"Min"`
+	Max int `xml:"Max"`
+}
+
+// 是否在取值范围内
+func (r IntRange) Contains(value int) bool {
+	return value >= r.Min && value <= r.Max
+}
+
+func (r IntRange) String() string {
+	return fmt.Sprintf("[%d, %d]", r.Min, r.Max)
+}
+
+// 视频源配置可选参数
+type VideoSourceConfigurationOptions struct {
+	BoundsRange                BoundsRange `xml:"BoundsRange"`
+	VideoSourceTokensAvailable []string    `xml:"VideoSourceTokensAvailable"`
+	RotateModes                []string    `xml:"Extension>Rotate>Mode"`
+}
+
+// 裁剪区域的取值范围
+type BoundsRange struct {
+	XRange      IntRange `xml:"XRange"`
+	YRange      IntRange `xml:"YRange"`
+	WidthRange  IntRange `xml:"WidthRange"`
+	HeightRange IntRange `xml:"HeightRange"`
+}
+
+// 裁剪区域是否在取值范围内
+func (r BoundsRange) Contains(bounds Bound) bool {
+	return r.XRange.Contains(bounds.X) && r.YRange.Contains(bounds.Y) &&
+		r.WidthRange.Contains(bounds.Width) && r.HeightRange.Contains(bounds.Height)
+}
+
+type setVideoSourceConfiguration struct {
+	Token       string                `xml:"token,attr"`
+	Name        string                `xml:"tt:Name"`
+	UseCount    int                   `xml:"tt:UseCount"`
+	SourceToken string                `xml:"tt:SourceToken"`
+	Bounds      Bound                 `xml:"tt:Bounds"`
+	Extension   *setVideoSourceRotate `xml:"tt:Extension,omitempty"`
+}
+
+type setVideoSourceRotate struct {
+	Mode string `xml:"tt:Rotate>tt:Mode"`
+}
+
+type VideoSourcesRequest struct {
+	XMLName string `xml:"trt:GetVideoSources"`
+}
+
+type VideoSourcesResponse struct {
+	XMLName      string        `xml:"Envelope"`
+	VideoSources []VideoSource `xml:"Body>GetVideoSourcesResponse>VideoSources"`
+}
+
+type VideoSourceConfigurationsRequest struct {
+	XMLName string `xml:"trt:GetVideoSourceConfigurations"`
+}
+
+type VideoSourceConfigurationsResponse struct {
+	XMLName        string                     `xml:"Envelope"`
+	Configurations []VideoSourceConfiguration `xml:"Body>GetVideoSourceConfigurationsResponse>Configurations"`
+}
+
+type VideoSourceConfigurationOptionsRequest struct {
+	XMLName            string `xml:"trt:GetVideoSourceConfigurationOptions"`
+	ConfigurationToken string `xml:"trt:ConfigurationToken,omitempty"`
+	ProfileToken       string `xml:"trt:ProfileToken,omitempty"`
+}
+
+type VideoSourceConfigurationOptionsResponse struct {
+	XMLName string                          `xml:"Envelope"`
+	Options VideoSourceConfigurationOptions `xml:"Body>GetVideoSourceConfigurationOptionsResponse>Options"`
+}
+
+type SetVideoSourceConfigurationRequest struct {
+	XMLName          string                      `xml:"trt:SetVideoSourceConfiguration"`
+	Configuration    setVideoSourceConfiguration `xml:"trt:Configuration"`
+	ForcePersistence bool                        `xml:"trt:ForcePersistence"`
+}
+
+type VideoSourceEmptyResponse struct {
+	XMLName string `xml:"Envelope"`
+}
+
+// 获取所有视频源，多目相机每个传感器对应一个视频源
+func (device *OnvifDevice) GetVideoSources() ([]VideoSource, error) {
+	mediaAddr, err := device.mediaServiceAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	var request VideoSourcesRequest
+	response := &VideoSourcesResponse{}
+	err = device.callMethod(mediaAddr, "http://www.onvif.org/ver10/media/wsdl/GetVideoSources", request, response)
+	if err != nil {
+		log.Println("GetVideoSources fail", err)
+		return nil, err
+	}
+
+	return response.VideoSources, nil
+}
+
+// 获取所有视频源配置
+func (device *OnvifDevice) GetVideoSourceConfigurations() ([]VideoSourceConfiguration, error) {
+	mediaAddr, err := device.mediaServiceAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	var request VideoSourceConfigurationsRequest
+	response := &VideoSourceConfigurationsResponse{}
+	err = device.callMethod(mediaAddr,
+		"http://www.onvif.org/ver10/media/wsdl/GetVideoSourceConfigurations", request, response)
+	if err != nil {
+		log.Println("GetVideoSourceConfigurations fail", err)
+		return nil, err
+	}
+
+	return response.Configurations, nil
+}
+
+// 获取视频源配置的可选参数，configurationToken、profileToken为空时不指定
+func (device *OnvifDevice) GetVideoSourceConfigurationOptions(configurationToken, profileToken string) (*VideoSourceConfigurationOptions, error) {
+	mediaAddr, err := device.mediaServiceAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	request := VideoSourceConfigurationOptionsRequest{
+		ConfigurationToken: configurationToken,
+		ProfileToken:       profileToken,
+	}
+	response := &VideoSourceConfigurationOptionsResponse{}
+	err = device.callMethod(mediaAddr,
+		"http://www.onvif.org/ver10/media/wsdl/GetVideoSourceConfigurationOptions", request, response)
+	if err != nil {
+		log.Println("GetVideoSourceConfigurationOptions fail", err)
+		return nil, err
+	}
+
+	return &response.Options, nil
+}
+
+// 修改视频源配置，例如裁剪传感器区域或切换视频源。
+// forcePersistence为false时设备重启后可能恢复原配置
+func (device *OnvifDevice) SetVideoSourceConfiguration(configuration VideoSourceConfiguration, forcePersistence bool) error {
+	if configuration.Token == "" {
+		return errors.New("video source configuration token is empty")
+	}
+	if configuration.SourceToken == "" {
+		return errors.New("video source token is empty")
+	}
+	if configuration.Bounds.Width <= 0 || configuration.Bounds.Height <= 0 {
+		return errors.New("video source bounds must have positive width and height")
+	}
+
+	mediaAddr, err := device.mediaServiceAddr()
+	if err != nil {
+		return err
+	}
+
+	request := SetVideoSourceConfigurationRequest{
+		Configuration: setVideoSourceConfiguration{
+			Token:       configuration.Token,
+			Name:        configuration.Name,
+			UseCount:    configuration.UseCount,
+			SourceToken: configuration.SourceToken,
+			Bounds:      configuration.Bounds,
+		},
+		ForcePersistence: forcePersistence,
+	}
+	if configuration.Mode != "" {
+		request.Configuration.Extension = &setVideoSourceRotate{Mode: configuration.Mode}
+	}
+	err = device.callMethod(mediaAddr,
+		"http://www.onvif.org/ver10/media/wsdl/SetVideoSourceConfiguration", request, &VideoSourceEmptyResponse{})
+	if err != nil {
+		log.Println("SetVideoSourceConfiguration fail", err)
+		return err
+	}
+
+	// 缓存的媒体文件中包含旧配置
+	device.Profile = nil
+	return nil
+}